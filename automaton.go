@@ -0,0 +1,458 @@
+package regrev
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// An automaton is a deterministic finite automaton that accepts the strings that match
+// a regex as a whole. It is built from the same parse of the regex that Reverse solves,
+// from parseSource. Strings Reverse produces are among the ones it accepts, except
+// where Reverse writes a character ahead of or after the match to satisfy an anchor,
+// as in "k!" for `\b!`.
+//
+// Unbounded repetitions loop in the automaton, rather than being spelled out
+// maxRepeats times, which would blow up regexes like `(a+b?)*`. Instead, the strings
+// it counts and walks through are those no longer than bound, the length of the
+// longest string the regex has when every unbounded repetition is capped the way
+// Reverse caps it.
+//
+// Its alphabet is split into atoms, ranges of characters that every state treats
+// alike, so strings are counted and walked atom by atom rather than character by
+// character.
+type automaton struct {
+	atoms  []atom
+	states []state
+	bound  int
+
+	liveMemo  map[[2]int]bool
+	countMemo map[[2]int]*big.Int
+	upToMemo  map[[2]int]*big.Int
+}
+
+type atom struct {
+	lo rune
+	hi rune
+}
+
+type state struct {
+	accept bool
+	edges  []edge
+}
+
+// Edges are kept in order of their atoms, which is also the order of their characters.
+type edge struct {
+	atom int
+	to   int
+}
+
+// The most states an automaton may have before regrev gives up on the regex.
+const maxStates = 1 << 16
+
+// The most lengths times states an automaton that loops may count strings for before
+// regrev gives up on the regex. Only looping automata count strings of every length up
+// to their bound, and they do so from each of their states.
+const maxCells = 1 << 20
+
+var errTooComplex = errors.New("is too complex for regrev to analyze")
+
+// What precedes a position in a string decides which anchors hold there, and these are
+// the kinds of character that anchors tell apart. Each has a character to stand in for
+// it, -1 for the start of the string.
+const (
+	kindStart = iota
+	kindNewline
+	kindWord
+	kindOther
+)
+
+var kindRunes = []rune{-1, '\n', 'a', '!'}
+
+func kindOf(r rune) int {
+	switch {
+	case r == '\n':
+		return kindNewline
+	case syntax.IsWordChar(r):
+		return kindWord
+	}
+	return kindOther
+}
+
+func (rr *RegexReverser) automaton(reg *regexp.Regexp) (*automaton, error) {
+	re, _, err := parseSource(reg)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(classes(re).Simplify())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compile regexp")
+	}
+	a, err := newAutomaton(prog)
+	if err != nil {
+		return nil, errors.Wrapf(err, "regexp %s", reg)
+	}
+
+	a.bound = rr.longestOf(re)
+	if !a.loops() {
+		// A finite language is counted in full, however long its strings.
+		a.bound = a.longest()
+	} else if a.bound > maxCells/len(a.states) {
+		return nil, errors.Wrapf(errTooComplex, "regexp %s", reg)
+	}
+	return a, nil
+}
+
+// Rewrites the parsed regex so every character is a range of the characters it can
+// be, leaving out the surrogates no string can hold.
+func classes(re *syntax.Regexp) *syntax.Regexp {
+	class := func(set runeSet) *syntax.Regexp {
+		return &syntax.Regexp{Op: syntax.OpCharClass, Rune: set.intersect(validRunes)}
+	}
+
+	switch re.Op {
+	case syntax.OpLiteral:
+		concat := &syntax.Regexp{Op: syntax.OpConcat}
+		for _, r := range re.Rune {
+			set := runeSet{r, r}
+			if re.Flags&syntax.FoldCase != 0 {
+				set = foldSet(r)
+			}
+			concat.Sub = append(concat.Sub, class(set))
+		}
+		return concat
+	case syntax.OpCharClass:
+		return class(runeSet(re.Rune))
+	}
+
+	rewritten := *re
+	rewritten.Sub = nil
+	for _, sub := range re.Sub {
+		rewritten.Sub = append(rewritten.Sub, classes(sub))
+	}
+	return &rewritten
+}
+
+// The length of the longest string the parsed regex has when every unbounded
+// repetition is capped the way Reverse caps it. Lengths past maxCells are too long to
+// count anyway, so they are cut short there.
+func (rr *RegexReverser) longestOf(re *syntax.Regexp) int {
+	longest := 0
+	switch re.Op {
+	case syntax.OpLiteral:
+		longest = len(re.Rune)
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		longest = 1
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			longest += rr.longestOf(sub)
+		}
+	case syntax.OpAlternate, syntax.OpCapture:
+		for _, sub := range re.Sub {
+			if l := rr.longestOf(sub); l > longest {
+				longest = l
+			}
+		}
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		repeats := rr.bound(repeatRange(re))
+		if repeats > maxCells {
+			repeats = maxCells
+		}
+		longest = repeats * rr.longestOf(re.Sub[0])
+	}
+
+	if longest > maxCells {
+		return maxCells + 1
+	}
+	return longest
+}
+
+// Builds the automaton for a compiled regex by subset construction. Each state is the
+// set of instructions waiting on the next character, each with the anchors passed on
+// the way to it, along with the kind of character before them, since that decides
+// which anchors hold.
+func newAutomaton(prog *syntax.Prog) (*automaton, error) {
+	a := &automaton{liveMemo: map[[2]int]bool{}}
+
+	kinds := map[int]runeSet{kindNewline: newlineSet, kindWord: wordSet, kindOther: otherSet}
+	allowed := func(kind int, anchors syntax.EmptyOp) runeSet {
+		set := runeSet{}
+		for _, k := range []int{kindNewline, kindWord, kindOther} {
+			if anchors&^syntax.EmptyOpContext(kindRunes[kind], kindRunes[k]) == 0 {
+				set = set.union(kinds[k])
+			}
+		}
+		return set
+	}
+
+	// Every set of characters the automaton follows is cut out of the characters of
+	// the instructions and the kinds, so splitting the alphabet wherever any of those
+	// start or stop splits it into atoms that every state treats alike.
+	sets := map[uint32]runeSet{}
+	alphabet := runeSet{}
+	for pc := range prog.Inst {
+		if set := instSet(&prog.Inst[pc]); set != nil {
+			sets[uint32(pc)] = set.intersect(validRunes)
+			alphabet = alphabet.union(sets[uint32(pc)])
+		}
+	}
+
+	cuts := []rune{}
+	cut := func(set runeSet) {
+		for j := 0; j < len(set); j += 2 {
+			cuts = append(cuts, set[j], set[j+1]+1)
+		}
+	}
+	for _, set := range sets {
+		cut(set)
+	}
+	cut(newlineSet)
+	cut(wordSet)
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+	for i := 0; i+1 < len(cuts); i++ {
+		if cuts[i] != cuts[i+1] && alphabet.contains(cuts[i]) {
+			a.atoms = append(a.atoms, atom{lo: cuts[i], hi: cuts[i+1] - 1})
+		}
+	}
+	atomsMemo := map[string][]int{}
+	atomsOf := func(set runeSet) []int {
+		key := fmt.Sprint(set)
+		if atoms, ok := atomsMemo[key]; ok {
+			return atoms
+		}
+		atoms := []int{}
+		for i, at := range a.atoms {
+			if set.contains(at.lo) {
+				atoms = append(atoms, i)
+			}
+		}
+		atomsMemo[key] = atoms
+		return atoms
+	}
+
+	type pending struct {
+		items []item
+		kind  int
+	}
+	ids := map[string]int{}
+	queue := []pending{}
+	add := func(items []item, kind int) (int, error) {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].pc != items[j].pc {
+				return items[i].pc < items[j].pc
+			}
+			return items[i].anchors < items[j].anchors
+		})
+		unique := items[:0]
+		for i, it := range items {
+			if i == 0 || it != items[i-1] {
+				unique = append(unique, it)
+			}
+		}
+
+		key := make([]string, 0, len(unique)+1)
+		key = append(key, strconv.Itoa(kind))
+		for _, it := range unique {
+			key = append(key, fmt.Sprintf("%d:%d", it.pc, it.anchors))
+		}
+		k := strings.Join(key, ",")
+		if id, ok := ids[k]; ok {
+			return id, nil
+		}
+		if len(a.states) >= maxStates {
+			return 0, errors.New("is too complex for regrev to analyze")
+		}
+
+		ids[k] = len(a.states)
+		a.states = append(a.states, state{})
+		queue = append(queue, pending{items: unique, kind: kind})
+		return ids[k], nil
+	}
+
+	if _, err := add(reach(prog, []uint32{uint32(prog.Start)}), kindStart); err != nil {
+		return nil, err
+	}
+	for id := 0; id < len(queue); id++ {
+		p := queue[id]
+		next := map[int][]uint32{}
+		for _, it := range p.items {
+			inst := &prog.Inst[it.pc]
+			if inst.Op == syntax.InstMatch {
+				if it.anchors&^syntax.EmptyOpContext(kindRunes[p.kind], -1) == 0 {
+					a.states[id].accept = true
+				}
+				continue
+			}
+			for _, i := range atomsOf(sets[it.pc].intersect(allowed(p.kind, it.anchors))) {
+				next[i] = append(next[i], inst.Out)
+			}
+		}
+
+		atoms := make([]int, 0, len(next))
+		for at := range next {
+			atoms = append(atoms, at)
+		}
+		sort.Ints(atoms)
+		for _, at := range atoms {
+			to, err := add(reach(prog, next[at]), kindOf(a.atoms[at].lo))
+			if err != nil {
+				return nil, err
+			}
+			a.states[id].edges = append(a.states[id].edges, edge{atom: at, to: to})
+		}
+	}
+	a.prune()
+	return a, nil
+}
+
+// Drops every edge into a state that can't reach an accepting one, so that the
+// automaton only loops where it accepts infinitely many strings.
+func (a *automaton) prune() {
+	into := make([][]int, len(a.states))
+	for s, st := range a.states {
+		for _, e := range st.edges {
+			into[e.to] = append(into[e.to], s)
+		}
+	}
+	useful := make([]bool, len(a.states))
+	stack := []int{}
+	for s, st := range a.states {
+		if st.accept {
+			useful[s] = true
+			stack = append(stack, s)
+		}
+	}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, from := range into[s] {
+			if !useful[from] {
+				useful[from] = true
+				stack = append(stack, from)
+			}
+		}
+	}
+
+	for s := range a.states {
+		edges := a.states[s].edges[:0]
+		for _, e := range a.states[s].edges {
+			if useful[e.to] {
+				edges = append(edges, e)
+			}
+		}
+		a.states[s].edges = edges
+	}
+}
+
+// An instruction waiting on a character, or the match instruction, along with the
+// anchors passed on the way to it since the last character.
+type item struct {
+	pc      uint32
+	anchors syntax.EmptyOp
+}
+
+// The characters an instruction consumes, if it consumes one.
+func instSet(inst *syntax.Inst) runeSet {
+	switch inst.Op {
+	case syntax.InstRune:
+		if len(inst.Rune) == 1 {
+			return runeSet{inst.Rune[0], inst.Rune[0]}
+		}
+		return runeSet(inst.Rune)
+	case syntax.InstRune1:
+		return runeSet{inst.Rune[0], inst.Rune[0]}
+	case syntax.InstRuneAny:
+		return runeSet{0, unicode.MaxRune}
+	case syntax.InstRuneAnyNotNL:
+		return runeSet{0, '\n' - 1, '\n' + 1, unicode.MaxRune}
+	}
+	return nil
+}
+
+// Follows every instruction that doesn't consume a character from the given ones,
+// returning the instructions left waiting on a character, and the match instruction
+// if it can be reached, each with the anchors passed on the way.
+func reach(prog *syntax.Prog, pcs []uint32) []item {
+	items := []item{}
+	seen := map[item]bool{}
+	stack := []item{}
+	for _, pc := range pcs {
+		stack = append(stack, item{pc: pc})
+	}
+
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[it] {
+			continue
+		}
+		seen[it] = true
+
+		inst := &prog.Inst[it.pc]
+		switch inst.Op {
+		case syntax.InstMatch, syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			items = append(items, it)
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, item{inst.Out, it.anchors}, item{inst.Arg, it.anchors})
+		case syntax.InstCapture, syntax.InstNop:
+			stack = append(stack, item{inst.Out, it.anchors})
+		case syntax.InstEmptyWidth:
+			stack = append(stack, item{inst.Out, it.anchors | syntax.EmptyOp(inst.Arg)})
+		}
+	}
+	return items
+}
+
+// Reports whether a string of exactly n more characters can take the state to an
+// accepting one.
+func (a *automaton) live(s, n int) bool {
+	if n == 0 {
+		return a.states[s].accept
+	}
+
+	key := [2]int{s, n}
+	if live, ok := a.liveMemo[key]; ok {
+		return live
+	}
+	live := false
+	for _, e := range a.states[s].edges {
+		if a.live(e.to, n-1) {
+			live = true
+			break
+		}
+	}
+	a.liveMemo[key] = live
+	return live
+}
+
+// The length of the longest string the automaton accepts, or -1 if it accepts none.
+// Only meaningful for automata that don't loop.
+func (a *automaton) longest() int {
+	memo := make([]int, len(a.states))
+	done := make([]bool, len(a.states))
+	var longest func(s int) int
+	longest = func(s int) int {
+		if done[s] {
+			return memo[s]
+		}
+		best := -1
+		if a.states[s].accept {
+			best = 0
+		}
+		for _, e := range a.states[s].edges {
+			if l := longest(e.to); l >= 0 && l+1 > best {
+				best = l + 1
+			}
+		}
+		memo[s], done[s] = best, true
+		return best
+	}
+	return longest(0)
+}
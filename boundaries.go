@@ -0,0 +1,125 @@
+package regrev
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// A Boundary is a string that sits on, or just past, the edge of one piece of a regex,
+// along with a label that says which piece and which edge, such as
+// "[A-F]{2,5} repeated 5 times" or "[A-F] as 'G'".
+type Boundary struct {
+	Label  string
+	String string
+}
+
+// A BoundarySet holds the boundary strings of a regex. Matching strings sit on the
+// edges of what the regex accepts, and NonMatching strings sit just past them.
+type BoundarySet struct {
+	Matching    []Boundary
+	NonMatching []Boundary
+}
+
+// Boundaries produces the boundary values of a regex, for testing the code that
+// validates it. Every repetition is taken to its minimum and maximum, and every range
+// to the lowest and highest character of each of its ranges. Just past those, every
+// repetition is taken one short of its minimum and one past its maximum, and every
+// range to the characters either side of each of its ranges. For `[A-F]{2,5}`, that's
+// strings of 2 and 5 characters, and ones starting with A and F, that match, and
+// strings of 1 and 6 characters, and ones starting with @ and G, that don't.
+//
+// Only one piece of the regex is on its boundary in each string, and everything else
+// is solved the way Reverse solves it, so UseStrategy(Shortest) keeps the rest of each
+// string as short as it can be. Strings are matched against the regex as a
+// whole, since `[A-F]{2,5}` would otherwise find a match in "AAAAAA". Boundaries the
+// regex can't reach, or can't step past without still matching, are left out.
+func (rr *RegexReverser) Boundaries(reg *regexp.Regexp) (*BoundarySet, error) {
+	comp, _, err := rr.parse(reg)
+	if err != nil {
+		return nil, err
+	}
+	whole, err := regexp.Compile(`\A(?:` + reg.String() + `)\z`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compile regexp")
+	}
+
+	rnd := rand.New(rand.NewSource(rr.nextSeed()))
+	memo := map[interface{}]*big.Int{}
+	solve := func(boundaries []Boundary, label string, e *edit, match bool) ([]Boundary, error) {
+		for i := 0; i < rr.attempts; i++ {
+			str, ok, err := edited(comp, &solution{rr: rr, rnd: rnd, memo: memo, edit: e, attempt: i})
+			if err != nil {
+				return nil, err
+			}
+			if ok && whole.MatchString(str) == match {
+				return append(boundaries, Boundary{Label: label, String: str}), nil
+			}
+		}
+		return boundaries, nil
+	}
+
+	bs := &BoundarySet{}
+	walk(comp, func(c component) {
+		if err != nil {
+			return
+		}
+		switch c := c.(type) {
+		case *repetition:
+			counts := []int{c.min}
+			if max := rr.bound(c.min, c.max); max != c.min {
+				counts = append(counts, max)
+			}
+			for _, k := range counts {
+				label := repeatedLabel(c, k)
+				if bs.Matching, err = solve(bs.Matching, label, &edit{site: c, repeats: k}, true); err != nil {
+					return
+				}
+			}
+
+			counts = []int{}
+			if c.min > 0 {
+				counts = append(counts, c.min-1)
+			}
+			if c.max != -1 {
+				counts = append(counts, c.max+1)
+			}
+			for _, k := range counts {
+				label := repeatedLabel(c, k)
+				if bs.NonMatching, err = solve(bs.NonMatching, label, &edit{site: c, repeats: k}, false); err != nil {
+					return
+				}
+			}
+		case *regRange:
+			for _, r := range c.regRange.endpoints() {
+				if !validRunes.contains(r) {
+					continue
+				}
+				label := fmt.Sprintf("%s as %q", c.re, r)
+				if bs.Matching, err = solve(bs.Matching, label, &edit{site: c, set: runeSet{r, r}}, true); err != nil {
+					return
+				}
+			}
+			for _, r := range c.regRange.neighbours() {
+				label := fmt.Sprintf("%s as %q", c.re, r)
+				if bs.NonMatching, err = solve(bs.NonMatching, label, &edit{site: c, set: runeSet{r, r}}, false); err != nil {
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+func repeatedLabel(r *repetition, k int) string {
+	if k == 1 {
+		return fmt.Sprintf("%s repeated once", r.re)
+	}
+	return fmt.Sprintf("%s repeated %d times", r.re, k)
+}
@@ -0,0 +1,72 @@
+package regrev_test
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestBoundaries(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.UseStrategy(regrev.Shortest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := rr.Boundaries(regexp.MustCompile(`[A-F]{2,5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &regrev.BoundarySet{
+		Matching: []regrev.Boundary{
+			{Label: "[A-F]{2,5} repeated 2 times", String: "AA"},
+			{Label: "[A-F]{2,5} repeated 5 times", String: "AAAAA"},
+			{Label: "[A-F] as 'A'", String: "AA"},
+			{Label: "[A-F] as 'F'", String: "FA"},
+		},
+		NonMatching: []regrev.Boundary{
+			{Label: "[A-F]{2,5} repeated once", String: "A"},
+			{Label: "[A-F]{2,5} repeated 6 times", String: "AAAAAA"},
+			{Label: "[A-F] as '@'", String: "@A"},
+			{Label: "[A-F] as 'G'", String: "GA"},
+		},
+	}
+	if !reflect.DeepEqual(bs, expected) {
+		t.Errorf("expected boundaries %v, got %v", expected, bs)
+	}
+}
+
+func TestBoundariesMatchAsAWhole(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`^v\d+\.(0|[1-9]\d?)$`),
+		regexp.MustCompile(`(?i)[a-c]{3}x*`),
+		regexp.MustCompile(`[^abc]x?`),
+		regexp.MustCompile(`(ab){2}|c+`),
+	}
+	for _, reg := range regs {
+		whole := regexp.MustCompile(`\A(?:` + reg.String() + `)\z`)
+		bs, err := rr.Boundaries(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bs.Matching) == 0 || len(bs.NonMatching) == 0 {
+			t.Errorf("expected %s to have boundaries either side, got %v", reg, bs)
+		}
+		for _, b := range bs.Matching {
+			if !whole.MatchString(b.String) {
+				t.Errorf("expected %s `%s` to match %s", b.Label, b.String, reg)
+			}
+		}
+		for _, b := range bs.NonMatching {
+			if whole.MatchString(b.String) {
+				t.Errorf("expected %s `%s` not to match %s", b.Label, b.String, reg)
+			}
+		}
+	}
+}
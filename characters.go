@@ -23,3 +23,63 @@ func Digits() []byte {
 func Whitespace() []byte {
 	return []byte{' ', '\t', '\r', '\n', '\f', '\v'}
 }
+
+// The tables below match the ASCII classes regexp/syntax accepts, such as [[:alnum:]]
+// and [[:punct:]].
+
+func Alpha() []byte {
+	result := []byte{}
+	result = append(result, AlphaUpper()...)
+	result = append(result, AlphaLower()...)
+	return result
+}
+
+func Alphanumeric() []byte {
+	result := []byte{}
+	result = append(result, Digits()...)
+	result = append(result, Alpha()...)
+	return result
+}
+
+func ASCII() []byte {
+	result := []byte{}
+	for c := 0; c <= 0x7f; c++ {
+		result = append(result, byte(c))
+	}
+	return result
+}
+
+func Blank() []byte {
+	return []byte{' ', '\t'}
+}
+
+func Control() []byte {
+	result := []byte{}
+	for c := 0; c < ' '; c++ {
+		result = append(result, byte(c))
+	}
+	return append(result, 0x7f)
+}
+
+func Graphical() []byte {
+	result := []byte{}
+	result = append(result, Alphanumeric()...)
+	result = append(result, Punctuation()...)
+	return result
+}
+
+func Printable() []byte {
+	return append([]byte{' '}, Graphical()...)
+}
+
+func Punctuation() []byte {
+	return []byte{'!', '"', '#', '$', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/', ':', ';', '<', '=', '>', '?', '@', '[', '\\', ']', '^', '_', '`', '{', '|', '}', '~'}
+}
+
+func Word() []byte {
+	return append(Alphanumeric(), '_')
+}
+
+func HexDigits() []byte {
+	return []byte{'0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'A', 'B', 'C', 'D', 'E', 'F', 'a', 'b', 'c', 'd', 'e', 'f'}
+}
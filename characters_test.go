@@ -0,0 +1,127 @@
+package regrev_test
+
+import (
+	"regexp"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestCharacterTables(t *testing.T) {
+	cases := []struct {
+		Class string
+		Table []byte
+	}{
+		{`[[:alnum:]]`, regrev.Alphanumeric()},
+		{`[[:alpha:]]`, regrev.Alpha()},
+		{`[[:ascii:]]`, regrev.ASCII()},
+		{`[[:blank:]]`, regrev.Blank()},
+		{`[[:cntrl:]]`, regrev.Control()},
+		{`[[:digit:]]`, regrev.Digits()},
+		{`[[:graph:]]`, regrev.Graphical()},
+		{`[[:lower:]]`, regrev.AlphaLower()},
+		{`[[:print:]]`, regrev.Printable()},
+		{`[[:punct:]]`, regrev.Punctuation()},
+		{`[[:space:]]`, regrev.Whitespace()},
+		{`[[:upper:]]`, regrev.AlphaUpper()},
+		{`[[:word:]]`, regrev.Word()},
+		{`[[:xdigit:]]`, regrev.HexDigits()},
+	}
+
+	for _, tc := range cases {
+		reg := regexp.MustCompile(`^` + tc.Class + `$`)
+		inTable := map[byte]bool{}
+		for _, c := range tc.Table {
+			inTable[c] = true
+		}
+		if len(inTable) != len(tc.Table) {
+			t.Errorf("expected no duplicates in the table for %s", tc.Class)
+		}
+		for c := 0; c <= 0x7f; c++ {
+			if reg.MatchString(string(rune(c))) != inTable[byte(c)] {
+				t.Errorf("expected the table for %s to agree with regexp about %q", tc.Class, rune(c))
+			}
+		}
+	}
+}
+
+func TestPOSIXClasses(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`^[[:alnum:]]{4}[[:xdigit:]]{4}[[:punct:]]{4}$`),
+		regexp.MustCompile(`^[[:^space:]]+[[:space:]][[:^alpha:][:upper:]]{3}$`),
+		regexp.MustCompile(`^[^[:cntrl:][:lower:]]+[[:blank:]]*$`),
+	}
+	for _, reg := range regs {
+		for i := 0; i < 20; i++ {
+			got, err := rr.Reverse(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reg.MatchString(got) {
+				t.Errorf("expected reversed string `%s` to match regexp %s", got, reg)
+			}
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	ascii, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	greek, err := regrev.NewRegexReverser(regrev.AllCharacterTable(unicode.Greek))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runes, err := regrev.NewRegexReverser(regrev.AllCharacterRunes([]rune("日本語ひらがな")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`^\p{Greek}+\pL{5}\p{Han}\PL$`),
+		regexp.MustCompile(`^[α-ω]{3}é+ü?$`),
+		regexp.MustCompile(`^[^a]{10}.{10}$`),
+		regexp.MustCompile(`^(?i)[α-ω]{3}σ$`),
+		regexp.MustCompile(`^\p{Hiragana}{3}[^\p{Hiragana}]$`),
+	}
+	for _, rr := range []*regrev.RegexReverser{ascii, greek, runes} {
+		for _, reg := range regs {
+			for i := 0; i < 20; i++ {
+				got, err := rr.Reverse(reg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !utf8.ValidString(got) {
+					t.Errorf("expected reversed string %q to be valid UTF-8", got)
+				}
+				if !reg.MatchString(got) {
+					t.Errorf("expected reversed string `%s` to match regexp %s", got, reg)
+				}
+			}
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		got, err := greek.Reverse(regexp.MustCompile(`^[^α-ω]{5}$`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range got {
+			if !unicode.Is(unicode.Greek, r) {
+				t.Errorf("expected only greek characters outside of α-ω, got `%s`", got)
+			}
+		}
+	}
+
+	if _, err := regrev.NewRegexReverser(regrev.AllCharacterRunes([]rune{0xd800})); err == nil {
+		t.Error("expected an error for a character set with no valid UTF-8 characters")
+	}
+}
@@ -0,0 +1,77 @@
+package regrev
+
+import (
+	"math/big"
+	"regexp"
+)
+
+// Count returns the number of distinct strings that match the regex as a whole, which
+// is also the number of strings Enumerate walks through. Every character the regex
+// allows is counted, so `.{3}` counts every string of three characters other than
+// newlines, not just the ones Reverse picks from. When the regex has infinitely many
+// strings, only those up to the length of its longest string with every unbounded
+// repetition capped at maxRepeats are counted, and infinite reports that there are
+// more.
+func (rr *RegexReverser) Count(reg *regexp.Regexp) (count *big.Int, infinite bool, err error) {
+	a, err := rr.automaton(reg)
+	if err != nil {
+		return nil, false, err
+	}
+	return new(big.Int).Set(a.upTo(0, a.bound)), a.loops(), nil
+}
+
+// The number of distinct strings of at most n more characters that take the state to
+// an accepting one.
+func (a *automaton) upTo(s, n int) *big.Int {
+	if a.upToMemo == nil {
+		a.upToMemo = map[[2]int]*big.Int{}
+	}
+	key := [2]int{s, n}
+	if total, ok := a.upToMemo[key]; ok {
+		return total
+	}
+
+	total := big.NewInt(0)
+	if n >= 0 && a.states[s].accept {
+		total.SetInt64(1)
+	}
+	if n > 0 {
+		for _, e := range a.states[s].edges {
+			paths := new(big.Int).Mul(a.atoms[e.atom].size(), a.upTo(e.to, n-1))
+			total.Add(total, paths)
+		}
+	}
+	a.upToMemo[key] = total
+	return total
+}
+
+func (at atom) size() *big.Int {
+	return big.NewInt(int64(at.hi-at.lo) + 1)
+}
+
+// Reports whether the automaton accepts infinitely many strings, which it does when it
+// loops anywhere it can reach from its start, since pruning leaves no loop that can't
+// reach an accepting state.
+func (a *automaton) loops() bool {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(a.states))
+	var loops func(s int) bool
+	loops = func(s int) bool {
+		marks[s] = visiting
+		for _, e := range a.states[s].edges {
+			if marks[e.to] == visiting {
+				return true
+			}
+			if marks[e.to] == unvisited && loops(e.to) {
+				return true
+			}
+		}
+		marks[s] = visited
+		return false
+	}
+	return loops(0)
+}
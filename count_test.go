@@ -0,0 +1,114 @@
+package regrev_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestCount(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.MaxRepeats(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Expected string
+		Infinite bool
+	}{
+		{regexp.MustCompile(`[A-Z0-9]{8}`), "2821109907456", false},
+		{regexp.MustCompile(`(dev|stg|prod)-(us|eu)-[0-9]`), "60", false},
+		{regexp.MustCompile(`a*`), "4", true},
+		{regexp.MustCompile(`a*a*`), "7", true},
+		{regexp.MustCompile(`(a|ab)(c|bcd)`), "4", false},
+		{regexp.MustCompile(`(?i)hello`), "32", false},
+		{regexp.MustCompile(`a^b`), "0", false},
+		{regexp.MustCompile(`(a^b)*c`), "1", false},
+		{regexp.MustCompile(`x{2,}`), "2", true},
+		{regexp.MustCompile(`(?:)*`), "1", false},
+		{regexp.MustCompile(`[^\x00-\x{10FFFF}]`), "0", false},
+		{regexp.MustCompile(`[a-z!]`), "27", false},
+		{regexp.MustCompile(`\b!`), "0", false},
+		{regexp.MustCompile(`(?i)k\B`), "1", false},
+		{regexp.MustCompile(`.{3}`), "1375270648056834047", false},
+		{regexp.MustCompile(`[^/]{2}`), "1236684115969", false},
+	}
+
+	for _, tc := range cases {
+		count, infinite, err := rr.Count(tc.Reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count.String() != tc.Expected {
+			t.Errorf("expected %s to count %s strings, got %s", tc.Reg, tc.Expected, count)
+		}
+		if infinite != tc.Infinite {
+			t.Errorf("expected %s to be infinite: %v", tc.Reg, tc.Infinite)
+		}
+	}
+}
+
+func TestCountMatchesEnumerate(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.MaxRepeats(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`(a|b|ab)*c?`),
+		regexp.MustCompile(`\b[a-c -]{0,3}\b`),
+		regexp.MustCompile(`(?m)(^x$\n?)+`),
+	}
+	for _, reg := range regs {
+		count, _, err := rr.Count(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, err := rr.Enumerate(reg, 100000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count.Int64() != int64(len(all)) {
+			t.Errorf("expected %s to count as many strings as it enumerates, %s and %d", reg, count, len(all))
+		}
+	}
+}
+
+func TestCountNestedRepetitions(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reg := range []*regexp.Regexp{
+		regexp.MustCompile(`(a+b?)*`),
+		regexp.MustCompile(`(\w+\s?)*`),
+		regexp.MustCompile(`(?:(?:a*)*)*`),
+	} {
+		count, infinite, err := rr.Count(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count.Sign() <= 0 || !infinite {
+			t.Errorf("expected %s to have infinitely many strings, got %s", reg, count)
+		}
+		if _, err := rr.Enumerate(reg, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Strings of a, up to 64*64*64 characters long, and the empty string.
+	count, _, err := rr.Count(regexp.MustCompile(`(?:(?:a*)*)*`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Int64() != 64*64*64+1 {
+		t.Errorf("expected %d strings, got %s", 64*64*64+1, count)
+	}
+
+	if _, _, err := rr.Count(regexp.MustCompile(`(?:(?:(?:a*)*)*)*`)); err == nil {
+		t.Error("expected an error for a regexp with strings too long to count")
+	}
+}
@@ -0,0 +1,294 @@
+package regrev
+
+import (
+	"math/big"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+)
+
+// A Coverage is a set of strings that together exercise every part of a regex, as
+// produced by Cover.
+type Coverage struct {
+	Strings []string
+
+	// Report holds what each part of the regex had to produce, keyed by the byte
+	// offset in the pattern where that part is written, or -1 if regrev couldn't
+	// place it.
+	Report map[int][]Goal
+}
+
+// A Goal is one thing a part of the regex can produce, such as one of its
+// alternatives, or a character from one of its ranges.
+type Goal struct {
+	// Expr is the part of the regex, as regexp/syntax writes it.
+	Expr string
+
+	// Label says what Expr had to produce: "alternative", "absent" or "present" for
+	// optional parts, or a range such as "range [A-F]".
+	Label string
+
+	// Index is the index into Strings of a string that covers the goal, or -1 if no
+	// string regrev can produce does.
+	Index int
+}
+
+// A goal is one choice of one component, which covering the regex has to make at
+// least once. For alternations, choice is the alternative. For optional
+// repetitions, it is 0 for absent and 1 for present. For ranges, it is the index of
+// one of the range's ranges.
+type goal struct {
+	c      component
+	choice int
+}
+
+// Keeps track of which goals are covered while producing a Cover. Goals covered by
+// strings already produced are true in goals, and goals covered by the string being
+// produced are in hits. An eager cover always steers towards goals that aren't yet
+// covered, otherwise it only does so half of the time, so that retries can get past
+// goals that can't be covered together.
+type cover struct {
+	goals map[goal]bool
+	hits  map[goal]bool
+	eager bool
+}
+
+// Cover produces a small set of strings that between them take every alternative of
+// every alternation, leave out and include every optional part of the regex, and
+// pick a character from every range of every character class. Each string is
+// produced the way Reverse produces it, but with its choices steered towards what the
+// strings before it haven't yet covered, and strings that turn out to cover nothing
+// the others don't are dropped, though there is always at least one string, even for
+// regexes like `abc` with nothing to cover. Parts of the regex that no string can
+// cover, such as `a^b` in `a^b|c`, are reported with an Index of -1, and a regex no
+// string can match at all returns an UnsatisfiableError.
+func (rr *RegexReverser) Cover(reg *regexp.Regexp) (*Coverage, error) {
+	comp, positions, err := rr.parse(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	goals, nodes, labels := goalsOf(comp)
+	cv := &cover{goals: map[goal]bool{}}
+	for _, g := range goals {
+		cv.goals[g] = false
+	}
+
+	rnd := rand.New(rand.NewSource(rr.nextSeed()))
+	memo := map[interface{}]*big.Int{}
+	strs := []string{}
+	hits := []map[goal]bool{}
+	for len(strs) == 0 || cv.uncovered() > 0 {
+		str, hit, err := cv.next(rr, comp, rnd, memo, len(strs) == 0)
+		if err != nil {
+			return nil, located(err, reg, positions)
+		}
+		if hit == nil {
+			break
+		}
+		strs = append(strs, str)
+		hits = append(hits, hit)
+		for g := range hit {
+			cv.goals[g] = true
+		}
+	}
+
+	// Drop strings that only cover goals the other strings cover too, latest first,
+	// since the earliest strings tend to cover the most.
+	for i := len(strs) - 1; i >= 0 && len(strs) > 1; i-- {
+		redundant := true
+		for g := range hits[i] {
+			others := false
+			for j := range hits {
+				if j != i && hits[j][g] {
+					others = true
+					break
+				}
+			}
+			if !others {
+				redundant = false
+				break
+			}
+		}
+		if redundant {
+			strs = append(strs[:i], strs[i+1:]...)
+			hits = append(hits[:i], hits[i+1:]...)
+		}
+	}
+
+	coverage := &Coverage{Strings: strs, Report: map[int][]Goal{}}
+	for i, g := range goals {
+		index := -1
+		for j := range hits {
+			if hits[j][g] {
+				index = j
+				break
+			}
+		}
+		offset := positions[nodes[i]].start
+		coverage.Report[offset] = append(coverage.Report[offset], Goal{
+			Expr:  nodes[i].String(),
+			Label: labels[i],
+			Index: index,
+		})
+	}
+	return coverage, nil
+}
+
+// Produces a string that covers at least one goal that isn't covered yet, and
+// returns the goals it covers. Returns no goals if no string can be found that
+// covers any more of them. The first string is taken whatever it covers, and if none
+// can be found, the reason the last attempt failed is returned.
+func (cv *cover) next(rr *RegexReverser, c component, rnd *rand.Rand, memo map[interface{}]*big.Int, first bool) (string, map[goal]bool, error) {
+	var unsatisfiable error
+	for i := 0; i < rr.attempts; i++ {
+		cv.hits = map[goal]bool{}
+		cv.eager = i == 0
+
+		s := &solution{rr: rr, rnd: rnd, memo: memo, cover: cv, attempt: i}
+		n, err := s.target(c)
+		if err != nil {
+			return "", nil, err
+		}
+		err = s.solveAll(c, n)
+		if _, ok := err.(*UnsatisfiableError); ok {
+			unsatisfiable = err
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		if first {
+			return s.out.String(), cv.hits, nil
+		}
+		for g := range cv.hits {
+			if !cv.goals[g] {
+				return s.out.String(), cv.hits, nil
+			}
+		}
+	}
+	if first {
+		return "", nil, unsatisfiable
+	}
+	return "", nil, nil
+}
+
+// The number of goals not yet covered.
+func (cv *cover) uncovered() int {
+	n := 0
+	for _, covered := range cv.goals {
+		if !covered {
+			n++
+		}
+	}
+	return n
+}
+
+// Every goal of the component and the components inside it, in the order they
+// appear in the regex, along with the node of the regex each belongs to and a label
+// for it.
+func goalsOf(c component) ([]goal, []*syntax.Regexp, []string) {
+	goals := []goal{}
+	nodes := []*syntax.Regexp{}
+	labels := []string{}
+	add := func(g goal, node *syntax.Regexp, label string) {
+		goals = append(goals, g)
+		nodes = append(nodes, node)
+		labels = append(labels, label)
+	}
+
+	walk(c, func(c component) {
+		switch c := c.(type) {
+		case *alternation:
+			for i := range c.alternatives {
+				add(goal{c, i}, c.re.Sub[i], "alternative")
+			}
+		case *repetition:
+			if c.min == 0 {
+				add(goal{c, 0}, c.re, "absent")
+			}
+			if c.min == 0 && c.max != 0 {
+				add(goal{c, 1}, c.re, "present")
+			}
+		case *regRange:
+			for i := 0; i < len(c.regRange); i += 2 {
+				r := runeSet{c.regRange[i], c.regRange[i+1]}
+				if r.intersect(validRunes).size() == 0 {
+					continue
+				}
+				class := &syntax.Regexp{Op: syntax.OpCharClass, Rune: r}
+				add(goal{c, i / 2}, c.re, "range "+class.String())
+			}
+		}
+	})
+	return goals, nodes, labels
+}
+
+// The goal that making the choice for the component would cover. Repetitions choose
+// a number of repeats, and ranges choose a character.
+func goalOf(c component, choice int) goal {
+	switch c := c.(type) {
+	case *repetition:
+		if choice > 0 {
+			choice = 1
+		}
+	case *regRange:
+		for i := 0; i < len(c.regRange); i += 2 {
+			if rune(choice) >= c.regRange[i] && rune(choice) <= c.regRange[i+1] {
+				return goal{c, i / 2}
+			}
+		}
+	}
+	return goal{c, choice}
+}
+
+// Narrows the choices for a component to those that would cover a goal that isn't
+// covered yet. Returns nothing if there are none, or if no Cover is being produced.
+func (s *solution) wanted(c component, choices []int) []int {
+	if s.cover == nil || (!s.cover.eager && s.rnd.Intn(2) == 0) {
+		return nil
+	}
+	wanted := []int{}
+	for _, choice := range choices {
+		g := goalOf(c, choice)
+		if covered, ok := s.cover.goals[g]; ok && !covered && !s.cover.hits[g] {
+			wanted = append(wanted, choice)
+		}
+	}
+	return wanted
+}
+
+// The characters a range should prefer, so that it covers one of its ranges that
+// isn't covered yet, narrowed the same way effective narrows the whole range.
+func (s *solution) wantedRanges(r *regRange) []runeSet {
+	if s.cover == nil || (!s.cover.eager && s.rnd.Intn(2) == 0) {
+		return nil
+	}
+	wanted := runeSet{}
+	for i := 0; i < len(r.regRange); i += 2 {
+		g := goal{r, i / 2}
+		if covered, ok := s.cover.goals[g]; ok && !covered && !s.cover.hits[g] {
+			wanted = wanted.union(r.regRange[i : i+2])
+		}
+	}
+	if wanted.size() == 0 {
+		return nil
+	}
+	preferred := []runeSet{}
+	for _, p := range s.rr.narrowing(r.regRange) {
+		preferred = append(preferred, wanted.intersect(p))
+	}
+	return append(preferred, wanted)
+}
+
+// Records that the choice made for the component covers its goal, if it has one.
+func (s *solution) hit(c component, choice int) {
+	if s.cover == nil {
+		return
+	}
+	g := goalOf(c, choice)
+	if _, ok := s.cover.goals[g]; ok {
+		s.cover.hits[g] = true
+	}
+}
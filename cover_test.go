@@ -0,0 +1,140 @@
+package regrev_test
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestCover(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`(GET|POST|PUT) /items(/\d+)?\?sort=[a-cx-z]`)
+	cov, err := rr.Cover(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range cov.Strings {
+		if !reg.MatchString(str) {
+			t.Errorf("expected `%s` to match %s", str, reg)
+		}
+	}
+
+	all := strings.Join(cov.Strings, "\n")
+	for _, sub := range []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^GET`),
+		regexp.MustCompile(`(?m)^POST`),
+		regexp.MustCompile(`(?m)^PUT`),
+		regexp.MustCompile(`items/\d`),
+		regexp.MustCompile(`items\?`),
+		regexp.MustCompile(`=[a-c]`),
+		regexp.MustCompile(`=[x-z]`),
+	} {
+		if !sub.MatchString(all) {
+			t.Errorf("expected %v to cover %s", cov.Strings, sub)
+		}
+	}
+	if len(cov.Strings) > 3 {
+		t.Errorf("expected at most 3 strings to cover %s, got %v", reg, cov.Strings)
+	}
+
+	for offset, goals := range cov.Report {
+		for _, g := range goals {
+			if g.Index < 0 || g.Index >= len(cov.Strings) {
+				t.Errorf("expected %s %s at %d to be covered, got index %d", g.Expr, g.Label, offset, g.Index)
+			}
+		}
+	}
+
+	expected := map[int][]string{
+		1:  {"GET alternative"},
+		5:  {"POST alternative"},
+		10: {"PUT alternative"},
+		21: {`(/[0-9]+)? absent`, `(/[0-9]+)? present`},
+		23: {"[0-9] range [0-9]"},
+		35: {"[a-cx-z] range [a-c]", "[a-cx-z] range [x-z]"},
+	}
+	report := map[int][]string{}
+	for offset, goals := range cov.Report {
+		for _, g := range goals {
+			report[offset] = append(report[offset], g.Expr+" "+g.Label)
+		}
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected report %v, got %v", expected, report)
+	}
+}
+
+func TestCoverUnreachable(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cov, err := rr.Cover(regexp.MustCompile(`a^b|c`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cov.Strings, []string{"c"}) {
+		t.Errorf("expected only `c` to cover a^b|c, got %v", cov.Strings)
+	}
+	expected := map[int][]regrev.Goal{
+		0: {{Expr: `a\Ab`, Label: "alternative", Index: -1}},
+		4: {{Expr: "c", Label: "alternative", Index: 0}},
+	}
+	if !reflect.DeepEqual(cov.Report, expected) {
+		t.Errorf("expected report %v, got %v", expected, cov.Report)
+	}
+}
+
+func TestCoverAlternatives(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		cov, err := rr.Cover(regexp.MustCompile(`(a|b|c)x?`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		all := strings.Join(cov.Strings, " ")
+		for _, sub := range []*regexp.Regexp{
+			regexp.MustCompile(`\ba`),
+			regexp.MustCompile(`\bb`),
+			regexp.MustCompile(`\bc`),
+			regexp.MustCompile(`x`),
+			regexp.MustCompile(`[abc]( |$)`),
+		} {
+			if !sub.MatchString(all) {
+				t.Errorf("expected %v to cover %s", cov.Strings, sub)
+			}
+		}
+	}
+}
+
+func TestCoverNothingToCover(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cov, err := rr.Cover(regexp.MustCompile(`abc`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cov.Strings, []string{"abc"}) {
+		t.Errorf("expected `abc` to cover abc, got %v", cov.Strings)
+	}
+
+	_, err = rr.Cover(regexp.MustCompile(`a^b`))
+	if _, ok := err.(*regrev.UnsatisfiableError); !ok {
+		t.Errorf("expected an UnsatisfiableError for a^b, got %v", err)
+	}
+}
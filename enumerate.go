@@ -0,0 +1,65 @@
+package regrev
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Enumerate returns the strings that match the regex as a whole, the same ones Count
+// counts, in order of length, then lexicographically. It stops after limit strings.
+// Regexes with infinitely many strings are walked up to the same length Count counts
+// them up to, so there is always a finite number of strings, but it can easily be more
+// than anyone would want to walk through.
+func (rr *RegexReverser) Enumerate(reg *regexp.Regexp, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, errors.New("Enumerate must be given a limit of at least one string")
+	}
+
+	results := []string{}
+	err := rr.EnumerateFunc(reg, func(s string) bool {
+		results = append(results, s)
+		return len(results) < limit
+	})
+	return results, err
+}
+
+// EnumerateFunc calls f with every string Enumerate would return, in the same order,
+// until f returns false.
+func (rr *RegexReverser) EnumerateFunc(reg *regexp.Regexp, f func(string) bool) error {
+	a, err := rr.automaton(reg)
+	if err != nil {
+		return err
+	}
+
+	for n := 0; n <= a.bound; n++ {
+		if !a.live(0, n) {
+			continue
+		}
+		if !a.walk(0, n, []rune{}, f) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Walks every string of exactly n more characters from the state, in lexicographic
+// order, calling f with each. Returns false once f does.
+func (a *automaton) walk(s, n int, prefix []rune, f func(string) bool) bool {
+	if n == 0 {
+		return f(string(prefix))
+	}
+
+	for _, e := range a.states[s].edges {
+		if !a.live(e.to, n-1) {
+			continue
+		}
+		at := a.atoms[e.atom]
+		for r := at.lo; r <= at.hi; r++ {
+			if !a.walk(e.to, n-1, append(prefix, r), f) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package regrev_test
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestEnumerate(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.MaxRepeats(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Expected []string
+	}{
+		{regexp.MustCompile(`a*`), []string{"", "a", "aa", "aaa"}},
+		{regexp.MustCompile(`[ab]{2}`), []string{"aa", "ab", "ba", "bb"}},
+		{regexp.MustCompile(`ab|b|a`), []string{"a", "b", "ab"}},
+		{regexp.MustCompile(`(?i)ok?`), []string{"O", "o", "OK", "Ok", "O\u212a", "oK", "ok", "o\u212a"}},
+		{regexp.MustCompile(`^(x|y)?$`), []string{"", "x", "y"}},
+		{regexp.MustCompile(`(?m)a$\s^b`), []string{"a\nb"}},
+		{regexp.MustCompile(`\ba\B[a-c]`), []string{"aa", "ab", "ac"}},
+		{regexp.MustCompile(`a^b`), []string{}},
+		{regexp.MustCompile(`x(a|ab)(c|bcd)`), []string{"xac", "xabc", "xabcd", "xabbcd"}},
+	}
+
+	for _, tc := range cases {
+		got, err := rr.Enumerate(tc.Reg, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.Expected) {
+			t.Errorf("expected %s to enumerate %q, got %q", tc.Reg, tc.Expected, got)
+		}
+	}
+}
+
+func TestEnumerateEnvironments(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`^(dev|stg|prod)-(us|eu)-[0-9]$`)
+	got, err := rr.Enumerate(reg, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 60 {
+		t.Fatalf("expected 60 strings, got %d", len(got))
+	}
+
+	seen := map[string]bool{}
+	for i, s := range got {
+		if !reg.MatchString(s) {
+			t.Errorf("expected enumerated string `%s` to match regexp %s", s, reg)
+		}
+		if seen[s] {
+			t.Errorf("expected `%s` to be enumerated once", s)
+		}
+		seen[s] = true
+		if i > 0 && (len(got[i-1]) > len(s) || len(got[i-1]) == len(s) && got[i-1] >= s) {
+			t.Errorf("expected `%s` to come before `%s`", got[i-1], s)
+		}
+	}
+	if got[0] != "dev-eu-0" || got[59] != "prod-us-9" {
+		t.Errorf("expected to start at dev-eu-0 and end at prod-us-9, got %s and %s", got[0], got[59])
+	}
+
+	limited, err := rr.Enumerate(regexp.MustCompile(`[a-z]+`), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(limited, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("expected the first five strings, got %q", limited)
+	}
+
+	count := 0
+	err = rr.EnumerateFunc(reg, func(s string) bool {
+		count++
+		return count < 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Errorf("expected EnumerateFunc to stop after 10 strings, got %d", count)
+	}
+}
@@ -0,0 +1,65 @@
+package regrev
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+)
+
+// An UnsatisfiableError means that the choices made while solving a regex have
+// produced a string that can't match it, or that a part of the regex can't match
+// anything at all. Depending on the regex, different choices might work, or none ever
+// will. The choices that failed last are reported, so the part of the regex it names
+// is the one that couldn't be satisfied on the last attempt.
+type UnsatisfiableError struct {
+	Pattern string
+
+	// Expr is the part of the regex as it is written in Pattern, from the byte offset
+	// Offset up to End. If regrev couldn't place it, Offset and End are -1, and Expr
+	// is the part as regexp/syntax writes it instead. Expr is empty when no one part is
+	// to blame, such as when no string fits the Length.
+	Expr   string
+	Offset int
+	End    int
+
+	re *syntax.Regexp
+}
+
+func (e *UnsatisfiableError) Error() string {
+	switch {
+	case e.Expr == "":
+		return fmt.Sprintf("regrev cannot satisfy regexp %s", e.Pattern)
+	case e.Offset < 0:
+		return fmt.Sprintf("regrev cannot satisfy %s where it appears in regexp %s", e.Expr, e.Pattern)
+	}
+	return fmt.Sprintf("regrev cannot satisfy %s at offset %d of regexp %s", e.Expr, e.Offset, e.Pattern)
+}
+
+// An ErrNoMatch is returned when every string regrev produced for a regex failed to
+// match it. Seed replays the call: a reverser created with Seed(Seed), and otherwise
+// the same options, makes the same choices on its first call.
+type ErrNoMatch struct {
+	Pattern   string
+	Candidate string
+	Seed      int64
+}
+
+func (e *ErrNoMatch) Error() string {
+	return fmt.Sprintf("regrev produced `%s` for regexp %s, which does not match it (seed %d)", e.Candidate, e.Pattern, e.Seed)
+}
+
+// Fills in the pattern of errors about a part of the regex, and where in the pattern
+// that part is written, as found by parseSource.
+func located(err error, reg *regexp.Regexp, positions map[*syntax.Regexp]span) error {
+	if e, ok := err.(*UnsatisfiableError); ok {
+		e.Pattern = reg.String()
+		e.Offset, e.End = -1, -1
+		if e.re != nil {
+			e.Expr = writtenAs(e.re, e.Pattern, positions)
+			if written, ok := positions[e.re]; ok && written.start >= 0 {
+				e.Offset, e.End = written.start, written.end
+			}
+		}
+	}
+	return err
+}
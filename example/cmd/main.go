@@ -7,6 +7,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/russellrollins/regrev"
 )
@@ -69,13 +70,22 @@ func run() error {
     </div>
     {{ if .RegexSucceeded }}
       <div>
-        <p>
-          regrev produced the string: {{.Response}} in response.
-        </p>
         {{ if .Matches }}
+          <p>
+            regrev produced the string: {{.Response}} in response.
+          </p>
           <p class="alert-success">A matching string!<p>
+        {{ else if .Mismatch }}
+          <p>
+            regrev produced the string: {{.Response}} in response.
+          </p>
+          <p class="alert-danger">A string that doesn't match, unfortunately. Looks like there's more cases to account for! Seed {{.Seed}} will reproduce it.</p>
         {{ else }}
-          <p class="alert-danger">A string that doesn't match, unfortunately. Looks like there's more cases to account for!</p>
+          <p class="alert-danger">regrev couldn't reverse that one: {{.Error}}</p>
+          {{ if .Caret }}
+            <pre>{{.Input}}
+{{.Caret}}</pre>
+          {{ end }}
         {{ end }}
         <a href="/"><button type="button" class="btn btn-primary">one 'mo 'gain?</button></a>
       </div>
@@ -98,17 +108,28 @@ func run() error {
 				regexSucceeded bool
 				response       string
 				matches        bool
+				mismatch       bool
+				seed           int64
+				reverseErr     string
+				caret          string
 			)
 			reg, err := regexp.Compile(inputReg)
 			if err == nil {
 				regexSucceeded = true
 				resp, err := reverse.Reverse(reg)
-				if err != nil {
-					matches = false
-					response = ""
-				} else {
+				switch err := err.(type) {
+				case nil:
 					response = resp
-					matches = reg.MatchString(resp)
+					matches = true
+				case *regrev.ErrNoMatch:
+					response = err.Candidate
+					mismatch = true
+					seed = err.Seed
+				case *regrev.UnsatisfiableError:
+					reverseErr = err.Error()
+					caret = pointAt(inputReg, err.Offset, err.End)
+				default:
+					reverseErr = err.Error()
 				}
 			}
 
@@ -117,14 +138,35 @@ func run() error {
 				RegexSucceeded bool
 				Response       string
 				Matches        bool
+				Mismatch       bool
+				Seed           int64
+				Error          string
+				Caret          string
 			}{
 				inputReg,
 				regexSucceeded,
 				response,
 				matches,
+				mismatch,
+				seed,
+				reverseErr,
+				caret,
 			})
 		}
 	})
 
 	return http.ListenAndServe(port, nil)
 }
+
+// Underlines the pattern from the byte offset start up to end, from the line beneath
+// it. Empty parts, such as the end of the pattern, are pointed at with a single caret.
+func pointAt(pattern string, start, end int) string {
+	if start < 0 || end < start || end > len(pattern) {
+		return ""
+	}
+	width := utf8.RuneCountInString(pattern[start:end])
+	if width < 1 {
+		width = 1
+	}
+	return strings.Repeat(" ", utf8.RuneCountInString(pattern[:start])) + strings.Repeat("^", width)
+}
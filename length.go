@@ -0,0 +1,231 @@
+package regrev
+
+import (
+	"math/big"
+	"regexp/syntax"
+
+	"github.com/pkg/errors"
+)
+
+// Length makes the reverser produce strings between min and max characters long,
+// inclusive. The reverser picks a length the regex can produce at random, then shares
+// it out between the pieces of the regex, choosing repeat counts and alternatives
+// that add up to it. Unbounded repetitions are still capped at maxRepeats, so
+// MaxRepeats may need to be raised for very long strings.
+func Length(min, max int) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		if min < 0 || max < min {
+			return errors.Errorf("Length must be configured with 0 <= min <= max, got %d and %d", min, max)
+		}
+		rr.lengthSet = true
+		rr.minLength = min
+		rr.maxLength = max
+		return nil
+	}
+}
+
+// The lengths of components are sets of bits, where bit n is set if the component can
+// solve to a string of n characters.
+
+func (c *compound) lengths(s *solution) *big.Int {
+	return s.suffix(c, 0)
+}
+
+func (l *literal) lengths(s *solution) *big.Int {
+	return single(len(l.literal))
+}
+
+func (sp *special) lengths(s *solution) *big.Int {
+	return single(1)
+}
+
+func (r *regRange) lengths(s *solution) *big.Int {
+	if r.regRange.size() == 0 {
+		return new(big.Int)
+	}
+	return single(1)
+}
+
+func (g *group) lengths(s *solution) *big.Int {
+	return s.lengthsOf(g.compound)
+}
+
+func (a *alternation) lengths(s *solution) *big.Int {
+	lengths := new(big.Int)
+	for _, alt := range a.alternatives {
+		lengths.Or(lengths, s.lengthsOf(alt))
+	}
+	return lengths
+}
+
+func (a *anchor) lengths(s *solution) *big.Int {
+	return single(0)
+}
+
+func (r *repetition) lengths(s *solution) *big.Int {
+	lengths := new(big.Int)
+	for k := r.min; k <= s.rr.bound(r.min, r.max); k++ {
+		lengths.Or(lengths, s.power(r, k))
+	}
+	return lengths
+}
+
+// The shortest and longest of a set of lengths, or -1 for both if the set is empty.
+func extent(lengths *big.Int) (int, int) {
+	if lengths.Sign() == 0 {
+		return -1, -1
+	}
+	return int(lengths.TrailingZeroBits()), lengths.BitLen() - 1
+}
+
+func single(n int) *big.Int {
+	return new(big.Int).SetBit(new(big.Int), n, 1)
+}
+
+// Every length that one string from each of two sets of lengths can add up to.
+func convolve(a, b *big.Int) *big.Int {
+	result := new(big.Int)
+	for i := 0; i < a.BitLen(); i++ {
+		if a.Bit(i) == 1 {
+			result.Or(result, new(big.Int).Lsh(b, uint(i)))
+		}
+	}
+	return result
+}
+
+func (s *solution) lengthsOf(c component) *big.Int {
+	if lengths, ok := s.memo[c]; ok {
+		return lengths
+	}
+	lengths := c.lengths(s)
+	s.memo[c] = lengths
+	return lengths
+}
+
+type suffixKey struct {
+	c *compound
+	i int
+}
+
+// The lengths the components of a compound can add up to, from the i'th onwards.
+func (s *solution) suffix(c *compound, i int) *big.Int {
+	key := suffixKey{c: c, i: i}
+	if lengths, ok := s.memo[key]; ok {
+		return lengths
+	}
+	lengths := single(0)
+	if i < len(c.compound) {
+		lengths = convolve(s.lengthsOf(c.compound[i]), s.suffix(c, i+1))
+	}
+	s.memo[key] = lengths
+	return lengths
+}
+
+type powerKey struct {
+	r *repetition
+	k int
+}
+
+// The lengths k repeats of a repetition can add up to.
+func (s *solution) power(r *repetition, k int) *big.Int {
+	key := powerKey{r: r, k: k}
+	if lengths, ok := s.memo[key]; ok {
+		return lengths
+	}
+	lengths := single(0)
+	if k > 0 {
+		lengths = convolve(s.lengthsOf(r.repeated), s.power(r, k-1))
+	}
+	s.memo[key] = lengths
+	return lengths
+}
+
+// Picks the length of the whole string, if the reverser is configured with Length.
+func (s *solution) target(c component) (int, error) {
+	if !s.rr.lengthSet {
+		return anyLength, nil
+	}
+
+	lengths := s.lengthsOf(c)
+	candidates := []int{}
+	for n := s.rr.minLength; n <= s.rr.maxLength && n < lengths.BitLen(); n++ {
+		if lengths.Bit(n) == 1 {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, &UnsatisfiableError{}
+	}
+	return s.pick(candidates), nil
+}
+
+// Picks the length of a component, the first of two runs of components, which must
+// add up to n with rest, the lengths of the second. Lengths after which the second
+// run, and the rest of the regex, can be solved in one of the contexts of after are
+// picked if there are any.
+func (s *solution) split(c component, after, rest *big.Int, n int) int {
+	first := s.lengthsOf(c)
+	candidates := []int{}
+	live := []int{}
+	for m := 0; m <= n && m < first.BitLen(); m++ {
+		if first.Bit(m) == 1 && rest.Bit(n-m) == 1 {
+			candidates = append(candidates, m)
+			if s.solvable(c, m, after) {
+				live = append(live, m)
+			}
+		}
+	}
+	if len(live) > 0 {
+		return s.pick(live)
+	}
+	return s.pick(candidates)
+}
+
+// Picks one of a list of numbers, in increasing order, according to the strategy.
+func (s *solution) pick(candidates []int) int {
+	switch s.rr.strategy {
+	case Shortest:
+		return candidates[s.rank(len(candidates))]
+	case Longest:
+		return candidates[len(candidates)-1-s.rank(len(candidates))]
+	}
+	return candidates[s.rnd.Intn(len(candidates))]
+}
+
+// Checks that a component, built from re, solves to a string of n characters, unless
+// n is anyLength.
+func (s *solution) fits(c component, re *syntax.Regexp, n int) error {
+	if n == anyLength || s.lengthsOf(c).Bit(n) == 1 {
+		return nil
+	}
+	return &UnsatisfiableError{re: re}
+}
+
+// Selects an alternative that can solve to a string of n characters.
+func (s *solution) branchLength(a *alternation, n int) (int, error) {
+	candidates := []int{}
+	for i, alt := range a.alternatives {
+		if s.lengthsOf(alt).Bit(n) == 1 {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) > 0 {
+		candidates = s.liveAlternatives(a, candidates, n)
+	}
+
+	if want := s.wanted(a, candidates); len(want) > 0 {
+		return s.pick(want), nil
+	}
+	if s.rr.branchSelector == nil {
+		return s.pick(candidates), nil
+	}
+
+	i, err := s.branch(a, anyLength)
+	if err != nil {
+		return 0, err
+	}
+	if s.lengthsOf(a.alternatives[i]).Bit(n) == 0 {
+		return 0, &UnsatisfiableError{re: a.re}
+	}
+	return i, nil
+}
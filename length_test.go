@@ -0,0 +1,94 @@
+package regrev_test
+
+import (
+	"regexp"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		Reg *regexp.Regexp
+		Min int
+		Max int
+	}{
+		{regexp.MustCompile(`^[A-Z][a-z]+( [A-Z][a-z]+)*$`), 20, 20},
+		{regexp.MustCompile(`^[A-Z][a-z]+( [A-Z][a-z]+)*$`), 5, 30},
+		{regexp.MustCompile(`^(ab|c)+d?$`), 7, 7},
+		{regexp.MustCompile(`^(?P<user>[a-z]{2,})@(example\.com|test\.org)$`), 14, 16},
+		{regexp.MustCompile(`^\w{3}(-\w{3}){2,}$`), 100, 120},
+		{regexp.MustCompile(`^(a|bb|ccc)*$`), 0, 0},
+		{regexp.MustCompile(`^\bé?x+\B.{2}\s?$`), 10, 10},
+	}
+
+	for _, tc := range cases {
+		rr, err := regrev.NewRegexReverser(regrev.Length(tc.Min, tc.Max))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			got, err := rr.Reverse(tc.Reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := utf8.RuneCountInString(got); n < tc.Min || n > tc.Max {
+				t.Errorf("expected `%s` to be between %d and %d characters long, got %d", got, tc.Min, tc.Max, n)
+			}
+			if !tc.Reg.MatchString(got) {
+				t.Errorf("expected reversed string `%s` to match regexp %s", got, tc.Reg)
+			}
+		}
+	}
+}
+
+func TestLengthShortestAndLongest(t *testing.T) {
+	reg := regexp.MustCompile(`^a{1,5}b{1,5}$`)
+
+	shortest, err := regrev.NewRegexReverser(regrev.Length(3, 8), regrev.UseStrategy(regrev.Shortest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := shortest.Reverse(reg); err != nil || got != "abb" {
+		t.Errorf("expected the shortest string of at least 3 characters to be `abb`, got `%s` %v", got, err)
+	}
+
+	longest, err := regrev.NewRegexReverser(regrev.Length(3, 8), regrev.UseStrategy(regrev.Longest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := longest.Reverse(reg); err != nil || got != "aaaaabbb" {
+		t.Errorf("expected the longest string of at most 8 characters to be `aaaaabbb`, got `%s` %v", got, err)
+	}
+}
+
+func TestImpossibleLength(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Length(5, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, reg := range []*regexp.Regexp{
+		regexp.MustCompile(`^[a-z]{3}$`),
+		regexp.MustCompile(`^(abc){4}$`),
+		regexp.MustCompile(`^(abcdefghijk|ab)$`),
+	} {
+		got, err := rr.Reverse(reg)
+		if _, ok := err.(*regrev.UnsatisfiableError); !ok {
+			t.Errorf("expected an UnsatisfiableError as no string of %s is 5 to 10 characters, got `%s` %v", reg, got, err)
+		}
+	}
+
+	rr, err = regrev.NewRegexReverser(regrev.Length(100, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rr.Reverse(regexp.MustCompile(`abc`))
+	if unsatisfiable, ok := err.(*regrev.UnsatisfiableError); !ok || unsatisfiable.Pattern != "abc" {
+		t.Errorf("expected an UnsatisfiableError for abc, as it is never 100 to 200 characters, got %v", err)
+	}
+
+	if _, err := regrev.NewRegexReverser(regrev.Length(10, 5)); err == nil {
+		t.Error("expected an error for a Length with min over max")
+	}
+}
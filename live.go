@@ -0,0 +1,374 @@
+package regrev
+
+import (
+	"math/big"
+	"regexp/syntax"
+	"unicode"
+)
+
+// A choice made while solving a regex can leave the rest of it impossible to solve,
+// such as taking `a\b` in `(a\b|b)+` on any but the last repeat, since the next "a"
+// or "b" can't satisfy the boundary. So before making a choice, regrev checks that the
+// rest of the regex can still be solved after it, and only makes the choices that
+// leave it solvable.
+//
+// Whether the rest of a regex can be solved only depends on the kind of the last
+// character written, and on the anchors pending after it. Together, they make a
+// context, numbered kind<<6 | pending. When the reverser is configured with Length, it
+// also depends on how many characters are left to write, so there is a level of
+// contexts for every number of characters left, up to maxLength. The contexts a
+// component can be solved in are sets of bits, where bit level*contextCount + context
+// is set if the rest of the regex can be solved after the component, when it is solved
+// in that context with that many characters left.
+
+// The number of contexts in each level.
+const contextCount = 4 << 6
+
+// Lengths longer than this aren't tracked level by level, since the sets of contexts
+// would get too large. Only the contexts themselves are checked for them.
+const maxLevels = 1 << 10
+
+func context(kind int, pending syntax.EmptyOp) int {
+	return kind<<6 | int(pending)
+}
+
+// Every context, and the kind and pending anchors of each.
+var contexts = func() [][2]int {
+	all := [][2]int{}
+	for _, kind := range []int{kindStart, kindNewline, kindWord, kindOther} {
+		for pending := 0; pending < 1<<6; pending++ {
+			all = append(all, [2]int{kind, pending})
+		}
+	}
+	return all
+}()
+
+// The kinds of character that can be written, each with its characters.
+var kindSets = map[int]runeSet{kindNewline: newlineSet, kindWord: wordSet, kindOther: otherSet}
+
+// The contexts in which a character can be written, when it can be one of the kinds
+// in the mask, bit 1<<kind set for each, for every mask. At the start of the string,
+// that includes writing a character ahead of it, the way write does.
+var characterBefore = func() []*big.Int {
+	befores := []*big.Int{}
+	for mask := 0; mask < 1<<len(kindRunes); mask++ {
+		before := new(big.Int)
+		for _, c := range contexts {
+			kind, pending := c[0], syntax.EmptyOp(c[1])
+			prevs := []int{kind}
+			if kind == kindStart {
+				prevs = append(prevs, kindWord, kindOther, kindNewline)
+			}
+			ok := false
+			for _, prev := range prevs {
+				for next := range kindSets {
+					ok = ok || (mask&(1<<uint(next)) != 0 && holds(pending, prev, next))
+				}
+			}
+			if ok {
+				before.SetBit(before, context(kind, pending), 1)
+			}
+		}
+		befores = append(befores, before)
+	}
+	return befores
+}()
+
+// The characters of the kinds in the mask.
+var kindMasks = func() []runeSet {
+	sets := []runeSet{}
+	for mask := 0; mask < 1<<len(kindRunes); mask++ {
+		set := runeSet{}
+		for kind, kindSet := range kindSets {
+			if mask&(1<<uint(kind)) != 0 {
+				set = set.union(kindSet)
+			}
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}()
+
+// Every kind of character that can be written.
+const allKinds = 1<<kindNewline | 1<<kindWord | 1<<kindOther
+
+// The kinds of the characters in the set, as a mask.
+func kinds(set runeSet) int {
+	mask := 0
+	for kind, kindSet := range kindSets {
+		if set.intersect(kindSet).size() > 0 {
+			mask |= 1 << uint(kind)
+		}
+	}
+	return mask
+}
+
+// Reports whether the pending anchors hold between a character of kind prev, and one
+// of kind next, or the end of the string if next is kindStart.
+func holds(pending syntax.EmptyOp, prev, next int) bool {
+	return pending&^syntax.EmptyOpContext(kindRunes[prev], kindRunes[next]) == 0
+}
+
+// The contexts finish can satisfy, with nothing left to write, either because the
+// pending anchors hold at the end of the string, or because a character written after
+// the match makes them hold.
+var finishable = func() *big.Int {
+	set := new(big.Int)
+	for _, c := range contexts {
+		kind, pending := c[0], syntax.EmptyOp(c[1])
+		ok := holds(pending, kind, kindStart)
+		for next := range kindSets {
+			ok = ok || holds(pending, kind, next)
+		}
+		if ok {
+			set.SetBit(set, context(kind, pending), 1)
+		}
+	}
+	return set
+}()
+
+// The bit for a context with left characters left to write, or -1 if there is none.
+func (s *solution) bit(left, c int) int {
+	if !s.leveled {
+		return c
+	}
+	if left < 0 || left > s.rr.maxLength {
+		return -1
+	}
+	return left*contextCount + c
+}
+
+// Reports whether the set has the context, with left characters left to write.
+func (s *solution) has(set *big.Int, left, c int) bool {
+	b := s.bit(left, c)
+	return b >= 0 && set.Bit(b) == 1
+}
+
+// The context the solution is in, after what it has written so far.
+func (s *solution) context() int {
+	kind := kindStart
+	if s.out.Len() > 0 {
+		kind = kindOf(s.last())
+	}
+	return context(kind, s.pending)
+}
+
+// Reports whether the rest of the regex can be solved in one of the contexts of after,
+// once a component is solved now, to n characters.
+func (s *solution) solvable(c component, n int, after *big.Int) bool {
+	return s.has(s.beforeOf(c, s.only(after, s.left-n, n)), s.left, s.context())
+}
+
+// The contexts of a set with left characters left to write. When n is anyLength, or
+// lengths aren't tracked, that is the whole set.
+func (s *solution) only(set *big.Int, left, n int) *big.Int {
+	if !s.leveled || n == anyLength {
+		return set
+	}
+	only := new(big.Int)
+	if left < 0 {
+		return only
+	}
+	for c := 0; c < contextCount; c++ {
+		only.SetBit(only, left*contextCount+c, set.Bit(left*contextCount+c))
+	}
+	return only
+}
+
+// Applies f to each level of contexts of after, which writes shift characters, to get
+// the level of contexts before it.
+func (s *solution) levels(after *big.Int, shift int, f func(after *big.Int) *big.Int) *big.Int {
+	if !s.leveled {
+		return f(after)
+	}
+	mask := new(big.Int).Lsh(big.NewInt(1), contextCount)
+	mask.Sub(mask, big.NewInt(1))
+	before := new(big.Int)
+	for left := 0; left+shift <= s.rr.maxLength && left*contextCount < after.BitLen(); left++ {
+		level := new(big.Int).Rsh(after, uint(left*contextCount))
+		if level.And(level, mask).Sign() == 0 {
+			continue
+		}
+		before.Or(before, new(big.Int).Lsh(f(level), uint((left+shift)*contextCount)))
+	}
+	return before
+}
+
+// The contexts in which one character of the set can be written, the way write writes
+// it, so that the rest of the regex can be solved after it in one of the contexts of
+// after.
+func (s *solution) beforeCharacter(set runeSet, after *big.Int) *big.Int {
+	mask := kinds(set.intersect(validRunes))
+	return s.levels(after, 1, func(after *big.Int) *big.Int {
+		return characterBefore[mask&liveKinds(after, 0)]
+	})
+}
+
+// The kinds of character after which one of the contexts in the level of contexts
+// can be reached, starting from the bit base.
+func liveKinds(after *big.Int, base int) int {
+	mask := 0
+	for kind := range kindSets {
+		if after.Bit(base+context(kind, 0)) == 1 {
+			mask |= 1 << uint(kind)
+		}
+	}
+	return mask
+}
+
+// The contexts in which each character of a run of sets can be written, so that the
+// rest of the regex can be solved after the run in one of the contexts of after. The
+// last entry is after itself.
+func (s *solution) beforeCharacters(sets []runeSet, after *big.Int) []*big.Int {
+	befores := make([]*big.Int, len(sets)+1)
+	befores[len(sets)] = after
+	for i := len(sets) - 1; i >= 0; i-- {
+		befores[i] = s.beforeCharacter(sets[i], befores[i+1])
+	}
+	return befores
+}
+
+func (c *compound) before(s *solution, after *big.Int) *big.Int {
+	for i := len(c.compound) - 1; i >= 0; i-- {
+		after = s.beforeOf(c.compound[i], after)
+	}
+	return after
+}
+
+func (l *literal) before(s *solution, after *big.Int) *big.Int {
+	return s.beforeCharacters(l.sets(), after)[0]
+}
+
+func (sp *special) before(s *solution, after *big.Int) *big.Int {
+	if sp.special == syntax.OpAnyCharNotNL {
+		return s.beforeCharacter(runeSet{0, '\n' - 1, '\n' + 1, unicode.MaxRune}, after)
+	}
+	return s.beforeCharacter(runeSet{0, unicode.MaxRune}, after)
+}
+
+func (r *regRange) before(s *solution, after *big.Int) *big.Int {
+	return s.beforeCharacter(r.regRange, after)
+}
+
+func (g *group) before(s *solution, after *big.Int) *big.Int {
+	return s.beforeOf(g.compound, after)
+}
+
+func (a *alternation) before(s *solution, after *big.Int) *big.Int {
+	before := new(big.Int)
+	for _, alt := range a.alternatives {
+		before.Or(before, s.beforeOf(alt, after))
+	}
+	return before
+}
+
+func (a *anchor) before(s *solution, after *big.Int) *big.Int {
+	return s.levels(after, 0, func(after *big.Int) *big.Int {
+		before := new(big.Int)
+		for _, c := range contexts {
+			kind, pending := c[0], syntax.EmptyOp(c[1])
+			if after.Bit(context(kind, pending|a.anchor)) == 1 {
+				before.SetBit(before, context(kind, pending), 1)
+			}
+		}
+		return before
+	})
+}
+
+func (r *repetition) before(s *solution, after *big.Int) *big.Int {
+	before := new(big.Int)
+	for _, b := range s.repeatsBefore(r, after, s.rr.bound(r.min, r.max))[r.min:] {
+		before.Or(before, b)
+	}
+	return before
+}
+
+type beforeKey struct {
+	c       component
+	after   string
+	leveled bool
+}
+
+func (s *solution) beforeOf(c component, after *big.Int) *big.Int {
+	key := beforeKey{c: c, after: string(after.Bytes()), leveled: s.leveled}
+	if before, ok := s.memo[key]; ok {
+		return before
+	}
+	before := c.before(s, after)
+	s.memo[key] = before
+	return before
+}
+
+// The contexts in which k repeats of a repetition can be solved, so that the rest of
+// the regex can be solved after them, for every k up to max.
+func (s *solution) repeatsBefore(r *repetition, after *big.Int, max int) []*big.Int {
+	befores := []*big.Int{after}
+	for k := 1; k <= max; k++ {
+		if k > 1 && befores[k-1].Cmp(befores[k-2]) == 0 {
+			// Once another repeat changes nothing, no number of them will.
+			befores = append(befores, befores[k-1])
+			continue
+		}
+		befores = append(befores, s.beforeOf(r.repeated, befores[k-1]))
+	}
+	return befores
+}
+
+// The kinds of character, as a mask, after which the rest of the regex can still be
+// solved.
+func (s *solution) live() int {
+	base := s.bit(s.left-1, 0)
+	if base < 0 {
+		return 0
+	}
+	return liveKinds(s.after, base)
+}
+
+// Narrows the numbers of repeats to those the rest of the regex can still be solved
+// after, given the contexts from repeatsBefore. If there are none, they're all kept, so
+// that solving fails where it would have without the check.
+func (s *solution) liveRepeats(counts []int, rest []*big.Int) []int {
+	live := []int{}
+	for _, k := range counts {
+		if s.has(rest[k], s.left, s.context()) {
+			live = append(live, k)
+		}
+	}
+	if len(live) == 0 {
+		return counts
+	}
+	return live
+}
+
+// Narrows the candidate alternatives to those the rest of the regex can still be
+// solved after, once the alternative is solved to n characters, keeping them all if
+// there are none.
+func (s *solution) liveAlternatives(a *alternation, candidates []int, n int) []int {
+	live := []int{}
+	for _, i := range candidates {
+		if s.solvable(a.alternatives[i], n, s.after) {
+			live = append(live, i)
+		}
+	}
+	if len(live) == 0 {
+		return candidates
+	}
+	return live
+}
+
+// Reports whether write can write a character from the set without breaking the
+// pending anchors, writing one ahead of it if nothing has been written yet.
+func (s *solution) writable(set runeSet) bool {
+	if set.intersect(s.allowed(s.last())).size() > 0 {
+		return true
+	}
+	if s.out.Len() > 0 {
+		return false
+	}
+	for _, kind := range []int{kindWord, kindOther, kindNewline} {
+		if set.intersect(s.allowed(kindRunes[kind])).size() > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package regrev
+
+import (
+	"math/big"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// An edit overrides one choice made while solving a regex: which characters one
+// character of a literal, special or range is picked from, or how many times a
+// repetition repeats. Edits needn't stay within the regex, which is how regrev
+// produces strings that almost match it.
+type edit struct {
+	site    component
+	index   int
+	set     runeSet
+	repeats int
+}
+
+// Returns the edit for the component, if it has one and it hasn't been used yet.
+func (s *solution) take(c component) *edit {
+	if s.edit == nil || s.edit.site != c {
+		return nil
+	}
+	e := s.edit
+	s.edit = nil
+	return e
+}
+
+// ReverseNonMatching produces a string that does not match the regex. Rather than
+// random noise, it solves the regex the way Reverse does, but gets exactly one thing
+// wrong: a character outside its range, or a repetition one short of its minimum or
+// one past its maximum. A regex can match anywhere in a string, so not every such
+// mistake stops it from matching, and every string is checked with reg.MatchString
+// before it is returned. If no mistake works, random strings from the all characters
+// set are tried instead. Some regexes, such as "a*", match every string, and
+// ReverseNonMatching returns an error for those.
+func (rr *RegexReverser) ReverseNonMatching(reg *regexp.Regexp) (string, error) {
+	comp, _, err := rr.parse(reg)
+	if err != nil {
+		return "", err
+	}
+
+	rnd := rand.New(rand.NewSource(rr.nextSeed()))
+	memo := map[interface{}]*big.Int{}
+	edits := mutations(comp)
+	for i := 0; i < rr.attempts && len(edits) > 0; i++ {
+		str, ok, err := edited(comp, &solution{rr: rr, rnd: rnd, memo: memo, edit: edits[rnd.Intn(len(edits))], attempt: i})
+		if err != nil {
+			return "", err
+		}
+		if ok && !reg.MatchString(str) {
+			return str, nil
+		}
+	}
+
+	min, max := 0, rr.maxRepeats
+	if rr.lengthSet {
+		min, max = rr.minLength, rr.maxLength
+		if max > min+rr.maxRepeats {
+			max = min + rr.maxRepeats
+		}
+	}
+	for i := 0; i < rr.attempts; i++ {
+		var b strings.Builder
+		for n := rnd.Intn(max-min+1) + min; n > 0; n-- {
+			b.WriteRune(rr.allCharactersSet.nth(rnd.Intn(rr.allCharactersSet.size())))
+		}
+		if !reg.MatchString(b.String()) {
+			return b.String(), nil
+		}
+	}
+	return "", errors.Errorf("regrev cannot find a string that does not match regexp %s", reg)
+}
+
+// Solves the component with the solution's edit. Reports false if the choices made
+// turned out to be unsatisfiable, or if the edit never took effect because its
+// component was skipped over by an alternation or a repetition.
+func edited(c component, s *solution) (string, bool, error) {
+	err := s.solveAll(c, anyLength)
+	if _, ok := err.(*UnsatisfiableError); ok {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return s.out.String(), s.edit == nil, nil
+}
+
+// Every edit that takes one piece of the regex just outside of what it accepts.
+func mutations(c component) []*edit {
+	edits := []*edit{}
+	walk(c, func(c component) {
+		switch c := c.(type) {
+		case *literal:
+			for i, r := range c.literal {
+				set := runeSet{r, r}
+				if c.re.Flags&syntax.FoldCase != 0 {
+					set = foldSet(r)
+				}
+				edits = append(edits, &edit{site: c, index: i, set: set.complement()})
+			}
+		case *special:
+			if c.special == syntax.OpAnyCharNotNL {
+				edits = append(edits, &edit{site: c, set: newlineSet})
+			}
+		case *regRange:
+			if set := c.regRange.complement().intersect(validRunes); set.size() > 0 {
+				edits = append(edits, &edit{site: c, set: set})
+			}
+		case *repetition:
+			if c.min > 0 {
+				edits = append(edits, &edit{site: c, repeats: c.min - 1})
+			}
+			if c.max != -1 {
+				edits = append(edits, &edit{site: c, repeats: c.max + 1})
+			}
+		}
+	})
+	return edits
+}
@@ -0,0 +1,81 @@
+package regrev_test
+
+import (
+	"regexp"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestReverseNonMatching(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`^[A-F]{2,5}$`),
+		regexp.MustCompile(`^\d{3}-\d{4}$`),
+		regexp.MustCompile(`(?i)^hello$`),
+		regexp.MustCompile(`^(GET|POST|PUT) /$`),
+		regexp.MustCompile(`^a.b$`),
+		regexp.MustCompile(`[0-9]`),
+		regexp.MustCompile(`^$`),
+		regexp.MustCompile(`x+`),
+	}
+	for _, reg := range regs {
+		for i := 0; i < 20; i++ {
+			str, err := rr.ReverseNonMatching(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reg.MatchString(str) {
+				t.Errorf("expected `%s` not to match %s", str, reg)
+			}
+		}
+	}
+}
+
+func TestReverseNonMatchingIsPlausible(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every string gets exactly one thing wrong, so it is either the right length
+	// with one character out of place, or a repetition one off.
+	reg := regexp.MustCompile(`^[A-F]{2,5}$`)
+	for i := 0; i < 50; i++ {
+		str, err := rr.ReverseNonMatching(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := utf8.RuneCountInString(str)
+		if n < 1 || n > 6 {
+			t.Errorf("expected `%s` to be between 1 and 6 characters long", str)
+		}
+		if n == 1 || n == 6 {
+			if !regexp.MustCompile(`^[A-F]+$`).MatchString(str) {
+				t.Errorf("expected `%s` to be one repeat off", str)
+			}
+			continue
+		}
+		if len(regexp.MustCompile(`[^A-F]`).FindAllString(str, -1)) != 1 {
+			t.Errorf("expected `%s` to have exactly one character out of place", str)
+		}
+	}
+}
+
+func TestReverseNonMatchingEverything(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reg := range []*regexp.Regexp{regexp.MustCompile(`a*`), regexp.MustCompile(``)} {
+		if str, err := rr.ReverseNonMatching(reg); err == nil {
+			t.Errorf("expected an error for %s, which matches every string, got `%s`", reg, str)
+		}
+	}
+}
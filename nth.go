@@ -0,0 +1,78 @@
+package regrev
+
+import (
+	"math/big"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Nth returns the string at index i of the strings that match the regex as a whole,
+// in the same order Enumerate walks them: by length, then lexicographically. Every
+// index from zero up to, but not including, Count's count maps to a different string,
+// so Nth can hand out stable, collision free values without storing anything.
+func (rr *RegexReverser) Nth(reg *regexp.Regexp, i *big.Int) (string, error) {
+	a, err := rr.automaton(reg)
+	if err != nil {
+		return "", err
+	}
+	total := a.upTo(0, a.bound)
+	if i.Sign() < 0 || i.Cmp(total) >= 0 {
+		return "", errors.Errorf("index %s is out of range for regexp %s, which has %s strings", i, reg, total)
+	}
+
+	i = new(big.Int).Set(i)
+	for n := 0; ; n++ {
+		count := a.count(0, n)
+		if i.Cmp(count) < 0 {
+			return string(a.unrank(0, n, true, i)), nil
+		}
+		i.Sub(i, count)
+	}
+}
+
+// Rank is the inverse of Nth, returning the index of a string that matches the regex as
+// a whole. Strings that only match by repeating something more than maxRepeats times
+// have no index.
+func (rr *RegexReverser) Rank(reg *regexp.Regexp, s string) (*big.Int, error) {
+	a, err := rr.automaton(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(s)
+	if len(runes) > a.bound {
+		return nil, errors.Errorf("`%s` is not one of the strings Nth produces for regexp %s", s, reg)
+	}
+	rank := big.NewInt(0)
+	for n := 0; n < len(runes); n++ {
+		rank.Add(rank, a.count(0, n))
+	}
+
+	st := 0
+	for k, r := range runes {
+		remaining := len(runes) - k - 1
+		found := false
+		for _, e := range a.states[st].edges {
+			at := a.atoms[e.atom]
+			each := a.count(e.to, remaining)
+			if r > at.hi {
+				rank.Add(rank, new(big.Int).Mul(at.size(), each))
+				continue
+			}
+			if r >= at.lo {
+				rank.Add(rank, new(big.Int).Mul(big.NewInt(int64(r-at.lo)), each))
+				st = e.to
+				found = true
+			}
+			break
+		}
+		if !found {
+			return nil, errors.Errorf("`%s` is not one of the strings Nth produces for regexp %s", s, reg)
+		}
+	}
+	if !a.states[st].accept {
+		return nil, errors.Errorf("`%s` is not one of the strings Nth produces for regexp %s", s, reg)
+	}
+	return rank, nil
+}
@@ -0,0 +1,124 @@
+package regrev_test
+
+import (
+	"math/big"
+	"regexp"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestNthAndRank(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.MaxRepeats(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`(dev|stg|prod)-(us|eu)-[0-9]`),
+		regexp.MustCompile(`(a|b|ab)*c?`),
+		regexp.MustCompile(`(?i)x[^a-z]?\b`),
+	}
+	for _, reg := range regs {
+		all, err := rr.Enumerate(reg, 10000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, expected := range all {
+			got, err := rr.Nth(reg, big.NewInt(int64(i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != expected {
+				t.Errorf("expected string %d of %s to be `%s`, got `%s`", i, reg, expected, got)
+			}
+
+			rank, err := rr.Rank(reg, expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rank.Int64() != int64(i) {
+				t.Errorf("expected `%s` to rank %d for %s, got %s", expected, i, reg, rank)
+			}
+		}
+
+		if _, err := rr.Nth(reg, big.NewInt(int64(len(all)))); err == nil {
+			t.Errorf("expected an error past the last string of %s", reg)
+		}
+		if _, err := rr.Nth(reg, big.NewInt(-1)); err == nil {
+			t.Errorf("expected an error for a negative index of %s", reg)
+		}
+	}
+}
+
+func TestNthAccountNumbers(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`^ACCT-[A-Z0-9]{12}$`)
+	customer := big.NewInt(12345)
+	first, err := rr.Nth(reg, customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := rr.Nth(reg, customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != again || !reg.MatchString(first) {
+		t.Errorf("expected customer 12345 to always get the same matching account, got `%s` and `%s`", first, again)
+	}
+
+	rank, err := rr.Rank(reg, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank.Cmp(customer) != 0 {
+		t.Errorf("expected `%s` to rank %s, got %s", first, customer, rank)
+	}
+
+	for _, s := range []string{"ACCT-abcdefghijkl", "ACCT-ABC", "nope"} {
+		if _, err := rr.Rank(reg, s); err == nil {
+			t.Errorf("expected an error ranking `%s`", s)
+		}
+	}
+}
+
+func TestRankReverse(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.MaxRepeats(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`\pL|é`),
+		regexp.MustCompile(`[^a]|é`),
+		regexp.MustCompile(`(\w|ü)+`),
+		regexp.MustCompile(`.x|\p{Greek}|λ`),
+		regexp.MustCompile(`[^/]{1,2}/(é|[^é])?`),
+		regexp.MustCompile(`(?i)[^a-z]|k|\x{212a}`),
+	}
+	for _, reg := range regs {
+		for i := 0; i < 200; i++ {
+			got, err := rr.Reverse(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rank, err := rr.Rank(reg, got)
+			if err != nil {
+				t.Errorf("expected `%s`, produced by Reverse, to rank for %s: %v", got, reg, err)
+				break
+			}
+			nth, err := rr.Nth(reg, rank)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nth != got {
+				t.Errorf("expected `%s` to be string %s of %s, got `%s`", got, rank, reg, nth)
+				break
+			}
+		}
+	}
+}
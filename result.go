@@ -0,0 +1,70 @@
+package regrev
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// A Result is a string produced by ReverseResult, along with what each capture
+// group of the regex matched in it.
+type Result struct {
+	String string
+
+	// Groups is indexed the same way as the submatches of the regex, so Groups[0]
+	// is the whole match and Groups[1] is the first capture group.
+	Groups []Group
+}
+
+// A Group is what one capture group of the regex matched. Start and End are byte
+// offsets into the Result's String, and are both -1 if the group didn't take part
+// in the match.
+type Group struct {
+	Name  string
+	Value string
+	Start int
+	End   int
+}
+
+// Named returns the group captured by the named capture group, such as
+// (?P<year>\d{4}).
+func (r *Result) Named(name string) (Group, bool) {
+	for _, g := range r.Groups {
+		if name != "" && g.Name == name {
+			return g, true
+		}
+	}
+	return Group{}, false
+}
+
+// ReverseResult reverses the regex the same way Reverse does, and also reports the
+// value and offsets of every capture group, exactly as reg.FindStringSubmatchIndex
+// reports them for the produced string.
+func (rr *RegexReverser) ReverseResult(reg *regexp.Regexp) (*Result, error) {
+	str, err := rr.Reverse(reg)
+	if err != nil {
+		return nil, err
+	}
+	return result(reg, str)
+}
+
+func result(reg *regexp.Regexp, str string) (*Result, error) {
+	indexes := reg.FindStringSubmatchIndex(str)
+	if indexes == nil {
+		return nil, errors.Errorf("reversed string `%s` does not match regexp %s", str, reg)
+	}
+
+	res := &Result{String: str}
+	for i, name := range reg.SubexpNames() {
+		g := Group{
+			Name:  name,
+			Start: indexes[2*i],
+			End:   indexes[2*i+1],
+		}
+		if g.Start >= 0 {
+			g.Value = str[g.Start:g.End]
+		}
+		res.Groups = append(res.Groups, g)
+	}
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package regrev_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestReverseResult(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`(?P<year>\d{4})-(?P<month>\d{2})(?:-(\d{2}))?(x)?`)
+	for i := 0; i < 20; i++ {
+		res, err := rr.ReverseResult(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		indexes := reg.FindStringSubmatchIndex(res.String)
+		if len(res.Groups)*2 != len(indexes) {
+			t.Fatalf("expected %d groups, got %d", len(indexes)/2, len(res.Groups))
+		}
+		for j, g := range res.Groups {
+			if g.Start != indexes[2*j] || g.End != indexes[2*j+1] {
+				t.Errorf("expected group %d of `%s` at %v, got [%d %d]", j, res.String, indexes[2*j:2*j+2], g.Start, g.End)
+			}
+			if g.Start >= 0 && g.Value != res.String[g.Start:g.End] {
+				t.Errorf("expected group %d value `%s`, got `%s`", j, res.String[g.Start:g.End], g.Value)
+			}
+		}
+
+		year, ok := res.Named("year")
+		if !ok {
+			t.Fatal("expected a group named year")
+		}
+		if year.Value != res.Groups[1].Value || len(year.Value) != 4 {
+			t.Errorf("expected year to be the four digit first group, got `%s`", year.Value)
+		}
+		if _, ok := res.Named("day"); ok {
+			t.Error("expected no group named day")
+		}
+	}
+}
+
+func TestReverseWith(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`(?P<user>[a-z]+)@(?P<domain>example\.com|test\.org)`)
+	for i := 0; i < 20; i++ {
+		got, err := rr.ReverseWith(reg, map[string]string{"user": "russell"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := reg.FindStringSubmatch(got)
+		if match == nil {
+			t.Fatalf("expected `%s` to match regexp %s", got, reg)
+		}
+		if match[1] != "russell" {
+			t.Errorf("expected user to be pinned to `russell`, got `%s`", match[1])
+		}
+	}
+
+	domains := []string{"example.com", "test.org"}
+	i := 0
+	next := func() string {
+		i++
+		return domains[i%2]
+	}
+	for j := 0; j < 4; j++ {
+		got, err := rr.ReverseWithFunc(reg, map[string]func() string{"domain": next})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match := reg.FindStringSubmatch(got); match == nil || match[2] != domains[i%2] {
+			t.Errorf("expected domain `%s` in `%s`", domains[i%2], got)
+		}
+	}
+
+	invalid := []map[string]string{
+		{"user": "Russell"},
+		{"domain": "example.org"},
+		{"host": "example.com"},
+	}
+	for _, values := range invalid {
+		if got, err := rr.ReverseWith(reg, values); err == nil {
+			t.Errorf("expected an error pinning %v, got `%s`", values, got)
+		}
+	}
+}
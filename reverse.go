@@ -1,70 +1,160 @@
 package regrev
 
 import (
-	"fmt"
+	"math/big"
 	"math/rand"
 	"regexp"
-	"strconv"
+	"regexp/syntax"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
 
-// TODO High Level
-//   1. Do I need to think about ^ or $? Maybe they just sort of don't matter
-//      in this project?
-//   2. Forgot how | or conditionals work, need to figure out how to handle them
-
+// A RegexReverser produces strings that match regular expressions. It is safe for
+// concurrent use by multiple goroutines.
 type RegexReverser struct {
 	maxRepeats       int
-	allCharactersSet []byte
+	allCharactersSet runeSet
 	whitespaceSet    []byte
+	branchSelector   func(alternatives []string) int
+	strategy         Strategy
+	lengthSet        bool
+	minLength        int
+	maxLength        int
+	attempts         int
+
+	// Every call makes its random choices from its own source, seeded with seed.
+	// Once a call takes its seed, rnd picks the seed for the next one. Neither is
+	// safe for concurrent use, so both are guarded by mu.
+	mu   sync.Mutex
+	seed int64
+	rnd  *rand.Rand
 }
 
 type component interface {
-	solve() (string, error)
+	// Solves the component to a string of exactly n characters, or of any length
+	// if n is anyLength.
+	solve(s *solution, n int) error
+
+	// Every length the component can solve to, in characters, as a set of bits.
+	// Call it through solution.lengthsOf, which remembers it.
+	lengths(s *solution) *big.Int
+
+	// The contexts the component can be solved in, so that the rest of the regex can
+	// be solved after it in one of the contexts of after. Call it through
+	// solution.beforeOf, which remembers it.
+	before(s *solution, after *big.Int) *big.Int
+}
+
+// Passed to solve when the component may solve to a string of any length.
+const anyLength = -1
+
+// We will solve the regex by recursively solving components. The regex is parsed
+// by regexp/syntax, exactly the way the regexp package parses it, and every node
+// of the parsed tree becomes a component. The degenerative case is a set of
+// solvable cases, such as literals and ranges. Other components cannot be solved
+// directly, but contain components themselves.
+
+// A solution collects the output of a single call to Reverse, as each component
+// is solved in turn. Anchors and boundaries don't write anything, instead they
+// are pending until the next character is written, which must satisfy them.
+type solution struct {
+	rr   *RegexReverser
+	rnd  *rand.Rand
+	out  strings.Builder
+	pins map[string]*pin
+
+	pending syntax.EmptyOp
+	anchor  *syntax.Regexp
+	newline bool
+
+	// The contexts the rest of the regex, after the component being solved, can be
+	// solved in. With Length, lengths are tracked along with them, and left is the
+	// number of characters the match has left to write.
+	after   *big.Int
+	leveled bool
+	left    int
+
+	// An edit overrides one choice of one component, the first time it is solved.
+	edit *edit
+
+	// While producing a Cover, choices are steered towards what isn't yet covered.
+	cover *cover
+
+	// Which attempt at solving the regex this is, counting from 0. On retries, the
+	// Shortest and Longest strategies step some choices past their first option.
+	attempt int
+
+	// Lengths of components, and of runs of components, along with the contexts
+	// they can be solved in, kept across attempts.
+	memo map[interface{}]*big.Int
 }
 
-// We will solve the regex by recursively splitting and solving components.
-// The degenerative case is a set of solvable cases, such as literals and
-// ranges. Other components cannot be solved directly, but contain componds
-// themselves.
+// A pin supplies the value of a named capture group, instead of solving it. Every
+// value is checked against the group's own regex before it is used.
+type pin struct {
+	value func() string
+	valid *regexp.Regexp
+}
 
 type compound struct {
-	rr       *RegexReverser
-	compound string
+	re       *syntax.Regexp
+	compound []component
 }
 
 type literal struct {
-	rr       *RegexReverser
-	literal  string
-	modifier string
+	re      *syntax.Regexp
+	literal []rune
 }
 
 type special struct {
-	rr       *RegexReverser
-	special  string
-	modifier string
+	re      *syntax.Regexp
+	special syntax.Op
 }
 
 type regRange struct {
-	rr       *RegexReverser
-	regRange string
-	modifier string
+	re       *syntax.Regexp
+	regRange runeSet
 }
 
 type group struct {
-	compound *compound
-	modifier string
+	re       *syntax.Regexp
+	compound component
+}
+
+type alternation struct {
+	re           *syntax.Regexp
+	alternatives []component
+
+	// Each alternative as it is written in the pattern, for the BranchSelector.
+	written []string
+}
+
+type anchor struct {
+	re     *syntax.Regexp
+	anchor syntax.EmptyOp
+}
+
+type repetition struct {
+	re       *syntax.Regexp
+	repeated component
+	min      int
+	max      int
 }
 
 func NewRegexReverser(options ...func(*RegexReverser) error) (*RegexReverser, error) {
 	r := &RegexReverser{
 		maxRepeats:       64,
-		allCharactersSet: AllCharacters(),
+		allCharactersSet: byteSet(AllCharacters()),
 		whitespaceSet:    Whitespace(),
+		attempts:         64,
 	}
 
+	options = append([]func(*RegexReverser) error{Seed(time.Now().UnixNano())}, options...)
 	for _, option := range options {
 		err := option(r)
 		if err != nil {
@@ -90,13 +180,97 @@ func AllCharacterSet(cs []byte) func(*RegexReverser) error {
 		if len(cs) < 1 {
 			return errors.New("empty character set provided to AllCharacterSet is not allowed")
 		}
-		rr.allCharactersSet = cs
+		rr.allCharactersSet = byteSet(cs)
+		return nil
+	}
+}
+
+// AllCharacterRunes is AllCharacterSet for sets that reach beyond ASCII.
+func AllCharacterRunes(cs []rune) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		set := runeList(cs).intersect(validRunes)
+		if set.size() < 1 {
+			return errors.New("empty character set provided to AllCharacterRunes is not allowed")
+		}
+		rr.allCharactersSet = set
+		return nil
+	}
+}
+
+// AllCharacterTable is AllCharacterSet for the tables of the unicode package, such
+// as unicode.Latin or unicode.Greek.
+func AllCharacterTable(tables ...*unicode.RangeTable) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		set := runeSet{}
+		for _, table := range tables {
+			set = set.union(tableSet(table))
+		}
+		set = set.intersect(validRunes)
+		if set.size() < 1 {
+			return errors.New("empty character set provided to AllCharacterTable is not allowed")
+		}
+		rr.allCharactersSet = set
+		return nil
+	}
+}
+
+// Seed makes the reverser's output reproducible. Two reversers given the same seed
+// produce the same strings, when given the same calls in the same order. By
+// default, the reverser is seeded from the current time.
+func Seed(seed int64) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		rr.seed = seed
+		rr.rnd = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// RandSource sets the source the reverser seeds each of its calls from.
+func RandSource(src rand.Source) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		if src == nil {
+			return errors.New("nil source provided to RandSource is not allowed")
+		}
+		rr.rnd = rand.New(src)
+		rr.seed = rr.rnd.Int63()
+		return nil
+	}
+}
+
+// BranchSelector controls which alternative of an alternation is solved. The selector
+// is given every alternative of the alternation, written as a regex, and returns the
+// index of the one to use. By default, alternatives are picked at random.
+//
+// Alternatives are handed to the selector as they are written in the pattern, so
+// `(GET|POST|PUT)` offers `GET`, `POST` and `PUT`, even though regexp/syntax would
+// factor them into `GET|P(?:OST|UT)` when compiling the regex, and `(?i:\d|x)`
+// offers `\d` and `x`, without the flags of the group around them.
+func BranchSelector(bs func(alternatives []string) int) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		if bs == nil {
+			return errors.New("nil function provided to BranchSelector is not allowed")
+		}
+		rr.branchSelector = bs
+		return nil
+	}
+}
+
+// Retries sets how many more times a call tries to solve a regex, when the choices it
+// made can't satisfy the regex, or produce a string that doesn't match it. By
+// default, a call retries 63 times, for 64 attempts in all.
+func Retries(n int) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		if n < 0 {
+			return errors.New("Retries must be configured with at least zero retries")
+		}
+		rr.attempts = n + 1
 		return nil
 	}
 }
 
 // If you're like me, you probably never want carriage returns or vertical tabs in your whitespace.
-func SaneWhitespace(cs []byte) func(*RegexReverser) error {
+// SaneWhitespace limits "\s" to spaces, tabs and newlines.
+func SaneWhitespace() func(*RegexReverser) error {
 	return func(rr *RegexReverser) error {
 		rr.whitespaceSet = []byte{' ', '\t', '\n'}
 		return nil
@@ -104,382 +278,664 @@ func SaneWhitespace(cs []byte) func(*RegexReverser) error {
 }
 
 func (rr *RegexReverser) Reverse(reg *regexp.Regexp) (string, error) {
-	// Create a group that is the whole regex, with no modifier
-	comp := &compound{
-		rr:       rr,
-		compound: reg.String(),
-	}
-
-	// Recursively solve the group by splitting it into component groups, solving them.
-	return comp.solve()
-}
-
-// There are four parts of a compound we must split:
-//   1. literals "a" "\(" etc.
-//   2. specials "." "\S" etc.
-//   3. ranges "[abc]" "[1-9]" etc
-//   4. groups, which are themselves compounds, but that we apply modifiers to.
-func (c *compound) split() []component {
-	splits := []component{}
-	escaped := false
-	for i := 0; i < len(c.compound); i++ {
-		char := c.compound[i]
-
-		// If we're not escaped, and get the escape character, enter escape and contine
-		if !escaped && char == '\\' {
-			escaped = true
-			continue
-		}
+	return rr.reverse(reg, nil)
+}
 
-		if escaped {
-			if reserved(char) {
-				// If we're escaped, and get a reserved character, that's just a literal
-				modifier, skip := extractModifier(c.compound, i+1)
-				i += skip
-				splits = append(splits, &literal{
-					rr:       c.rr,
-					literal:  fmt.Sprintf("\\%s", string(char)),
-					modifier: modifier,
-				})
-			} else {
-				// If we're escaped, and get a non-reserved character, that's a special
-				modifier, skip := extractModifier(c.compound, i+1)
-				i += skip
-				splits = append(splits, &special{
-					rr:       c.rr,
-					special:  fmt.Sprintf("\\%s", string(char)),
-					modifier: modifier,
-				})
-			}
-			escaped = false
-			continue
-		}
+// ReverseWith reverses the regex, but uses the given values for its named capture
+// groups, rather than solving them. Every value must match the sub-pattern of its
+// group.
+func (rr *RegexReverser) ReverseWith(reg *regexp.Regexp, values map[string]string) (string, error) {
+	funcs := make(map[string]func() string, len(values))
+	for name, value := range values {
+		value := value
+		funcs[name] = func() string { return value }
+	}
+	return rr.ReverseWithFunc(reg, funcs)
+}
 
-		// if this is not a reserved character, it's just a literal
-		if !reserved(char) {
-			modifier, skip := extractModifier(c.compound, i+1)
-			i += skip
-			splits = append(splits, &literal{
-				rr:       c.rr,
-				literal:  string(char),
-				modifier: modifier,
-			})
-			continue
+// ReverseWithFunc reverses the regex, but calls the given functions for the values
+// of its named capture groups, rather than solving them. Every value must match the
+// sub-pattern of its group.
+func (rr *RegexReverser) ReverseWithFunc(reg *regexp.Regexp, funcs map[string]func() string) (string, error) {
+	pins := make(map[string]*pin, len(funcs))
+	for name, f := range funcs {
+		if reg.SubexpIndex(name) < 0 {
+			return "", errors.Errorf("regexp %s has no capture group named %s", reg, name)
+		}
+		if f == nil {
+			return "", errors.Errorf("nil function provided for capture group %s is not allowed", name)
 		}
+		pins[name] = &pin{value: f}
+	}
+	return rr.reverse(reg, pins)
+}
 
-		// next, check for the start of a range
-		if char == '[' {
-			escaped := false
-			skip := 0
-			for j := i + 1; j < len(c.compound); j++ {
-				if escaped {
-					escaped = false
-					continue
-				}
+func (rr *RegexReverser) reverse(reg *regexp.Regexp, pins map[string]*pin) (string, error) {
+	// Parse the regex the same way regexp.Compile does, then build a component
+	// out of the whole tree.
+	comp, positions, err := rr.parse(reg)
+	if err != nil {
+		return "", err
+	}
 
-				charJ := c.compound[j]
-				if charJ == '\\' {
-					escaped = true
-					continue
-				}
+	// Recursively solve the tree by solving each of its components. If the choices
+	// made along the way can't satisfy the regex, or the string doesn't match it
+	// after all, try again.
+	seed := rr.nextSeed()
+	rnd := rand.New(rand.NewSource(seed))
+	memo := map[interface{}]*big.Int{}
+	for i := 0; ; i++ {
+		s := &solution{rr: rr, rnd: rnd, pins: pins, memo: memo, attempt: i}
+		n, err := s.target(comp)
+		if err != nil {
+			return "", located(err, reg, positions)
+		}
+		err = s.solveAll(comp, n)
+		if err == nil && !reg.MatchString(s.out.String()) {
+			err = &ErrNoMatch{Pattern: reg.String(), Candidate: s.out.String(), Seed: seed}
+		}
+		if err == nil {
+			return s.out.String(), nil
+		}
 
-				if charJ == ']' {
-					skip = (j - i)
-					break
-				}
-			}
-			r := &regRange{
-				rr:       c.rr,
-				regRange: c.compound[i+1 : i+skip],
+		switch err.(type) {
+		case *UnsatisfiableError, *ErrNoMatch:
+			if i == rr.attempts-1 {
+				return "", located(err, reg, positions)
 			}
-			i += skip
-			modifier, skip := extractModifier(c.compound, i+1)
-			i += skip
-			r.modifier = modifier
-			splits = append(splits, r)
-			continue
+		default:
+			return "", err
 		}
+	}
+}
 
-		// finally, check for the start of a group
-		if char == '(' {
-			// TODO remove non-caputuring group or named capture group syntax first
-			escaped := false
-			skip := 0
-			for j := i + 1; j < len(c.compound); j++ {
-				if escaped {
-					escaped = false
-					continue
-				}
+// Hands out the seed for one call, so that calls running at the same time each make
+// their choices from their own source.
+func (rr *RegexReverser) nextSeed() int64 {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
 
-				charJ := c.compound[j]
-				if charJ == '\\' {
-					escaped = true
-					continue
-				}
+	seed := rr.seed
+	rr.seed = rr.rnd.Int63()
+	return seed
+}
 
-				if charJ == ')' {
-					skip = (j - i)
-					break
-				}
-			}
-			g := &group{
-				compound: &compound{
-					rr:       c.rr,
-					compound: c.compound[i+1 : i+skip],
-				},
+// Parses the regex and builds a component out of the whole tree, returning it along
+// with where each node of the parsed regex is written in the pattern.
+func (rr *RegexReverser) parse(reg *regexp.Regexp) (component, map[*syntax.Regexp]span, error) {
+	re, positions, err := parseSource(reg)
+	if err != nil {
+		return nil, nil, err
+	}
+	comp := rr.component(re)
+	walk(comp, func(c component) {
+		if a, ok := c.(*alternation); ok {
+			for _, sub := range a.re.Sub {
+				a.written = append(a.written, writtenAs(sub, reg.String(), positions))
 			}
-			i += skip
-			modifier, skip := extractModifier(c.compound, i+1)
-			i += skip
-			g.modifier = modifier
-			splits = append(splits, g)
-			continue
 		}
+	})
+	return comp, positions, nil
+}
+
+// Parses the regex the same way regexp.Compile does.
+func parseRegexp(reg *regexp.Regexp) (*syntax.Regexp, error) {
+	re, err := syntax.Parse(reg.String(), syntax.Perl)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse regexp")
+	}
+	return re, nil
+}
+
+// Turns a node of the parsed regex into the component that solves it. There are
+// seven kinds of component:
+//  1. literals "a" "\(" etc.
+//  2. specials "." etc.
+//  3. ranges "[abc]" "[1-9]" "\d" etc.
+//  4. groups and compounds, which contain other components.
+//  5. alternations "a|b", which solve one of many components.
+//  6. repetitions "?" "*" "+" "{2,5}", which solve a component many times.
+//  7. anchors "^" "$" "\b" etc, which constrain the characters around them.
+func (rr *RegexReverser) component(re *syntax.Regexp) component {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return &compound{re: re}
+	case syntax.OpLiteral:
+		return &literal{re: re, literal: re.Rune}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return &special{re: re, special: re.Op}
+	case syntax.OpCharClass:
+		return &regRange{re: re, regRange: runeSet(re.Rune)}
+	case syntax.OpCapture:
+		return &group{re: re, compound: rr.component(re.Sub[0])}
+	case syntax.OpConcat:
+		c := &compound{re: re}
+		for _, sub := range re.Sub {
+			c.compound = append(c.compound, rr.component(sub))
+		}
+		return c
+	case syntax.OpAlternate:
+		a := &alternation{re: re}
+		for _, sub := range re.Sub {
+			a.alternatives = append(a.alternatives, rr.component(sub))
+		}
+		return a
+	case syntax.OpBeginLine:
+		return &anchor{re: re, anchor: syntax.EmptyBeginLine}
+	case syntax.OpEndLine:
+		return &anchor{re: re, anchor: syntax.EmptyEndLine}
+	case syntax.OpBeginText:
+		return &anchor{re: re, anchor: syntax.EmptyBeginText}
+	case syntax.OpEndText:
+		return &anchor{re: re, anchor: syntax.EmptyEndText}
+	case syntax.OpWordBoundary:
+		return &anchor{re: re, anchor: syntax.EmptyWordBoundary}
+	case syntax.OpNoWordBoundary:
+		return &anchor{re: re, anchor: syntax.EmptyNoWordBoundary}
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		r := &repetition{re: re, repeated: rr.component(re.Sub[0])}
+		r.min, r.max = repeatRange(re)
+		return r
+	}
 
-		// if it's none of those things, it ought to be a special
-		modifier, skip := extractModifier(c.compound, i+1)
-		i += skip
-		splits = append(splits, &special{
-			rr:       c.rr,
-			special:  string(char),
-			modifier: modifier,
-		})
+	// The only op left is OpNoMatch, which regexp/syntax only produces when it
+	// simplifies a regex. Like an empty character class, it matches nothing.
+	return &regRange{re: re}
+}
+
+// The fewest and the most times a repetition repeats, the most being -1 if it is
+// unbounded.
+func repeatRange(re *syntax.Regexp) (int, int) {
+	switch re.Op {
+	case syntax.OpStar:
+		return 0, -1
+	case syntax.OpPlus:
+		return 1, -1
+	case syntax.OpQuest:
+		return 0, 1
 	}
-	return splits
+	return re.Min, re.Max
 }
 
-// Determines whether a character belongs to the regexp reserved syntax
-func reserved(c byte) bool {
-	rcs := []byte{'[', '\\', '^', '$', '.', '|', '?', '*', '+', '(', ')'}
-	found := false
-	for _, rc := range rcs {
-		if c == rc {
-			found = true
-			break
+// Calls f with the component, then with every component inside it, in the order
+// they appear in the regex.
+func walk(c component, f func(component)) {
+	f(c)
+	switch c := c.(type) {
+	case *compound:
+		for _, comp := range c.compound {
+			walk(comp, f)
 		}
+	case *group:
+		walk(c.compound, f)
+	case *alternation:
+		for _, alt := range c.alternatives {
+			walk(alt, f)
+		}
+	case *repetition:
+		walk(c.repeated, f)
 	}
-	return found
 }
 
-// Takes a string, and the index of character. Determines the modifier applies to that character
-// and the length of said modifier.
-func extractModifier(s string, i int) (string, int) {
-	// After the last character, there can be no modifier.
-	if i >= len(s) {
-		return "", 0
+// Solves the whole regex, from the start of the string to its end.
+func (s *solution) solveAll(c component, n int) error {
+	s.after = finishable
+	s.leveled = n != anyLength && s.rr.maxLength <= maxLevels
+	s.left = n
+	if err := c.solve(s, n); err != nil {
+		return err
 	}
+	return s.finish()
+}
 
-	char := s[i]
-	if char == '?' || char == '*' || char == '+' {
-		// Simple modifiers
-		return string(char), 1
-	} else if char == '{' {
-		// TODO revisit this logic it is wrong! (comment is more accurate)
-		// { can be a modifier, but only if it contains either a single digit,
-		// or two digits seperated by a comma!
-		skip := 0
-		for j := i + 1; j < len(s); j++ {
-			charJ := s[j]
+// To solve a compound, solve each of its components in order. When a single
+// character is followed by the start of a line, it has to be a newline if it can be.
+func (c *compound) solve(s *solution, n int) error {
+	after := s.after
+	rest := make([]*big.Int, len(c.compound)+1)
+	rest[len(c.compound)] = s.only(after, s.left-n, n)
+	for i := len(c.compound) - 1; i > 0; i-- {
+		rest[i] = s.beforeOf(c.compound[i], rest[i+1])
+	}
 
-			if charJ == '{' {
-				break
-			}
+	for i, comp := range c.compound {
+		if i+1 < len(c.compound) && singleCharacter(comp) && beginsLine(c.compound[i+1:]...) {
+			s.newline = true
+		}
 
-			if charJ == '}' {
-				skip = (j - i) + 1
-				break
+		compN := anyLength
+		if n != anyLength {
+			compN = s.split(comp, rest[i+1], s.suffix(c, i+1), n)
+			n -= compN
+		}
+		s.after = rest[i+1]
+		if err := comp.solve(s, compN); err != nil {
+			return err
+		}
+	}
+	s.after = after
+	return nil
+}
+
+// Reports whether the component writes a single character it chooses, such as "." or
+// "([a-z])".
+func singleCharacter(c component) bool {
+	switch c := c.(type) {
+	case *special, *regRange:
+		return true
+	case *group:
+		return singleCharacter(c.compound)
+	}
+	return false
+}
+
+// Reports whether a run of components can start with the start of a line, such as
+// "^a", "(^a)" or "(^a|b)", looking past any other anchors before it.
+func beginsLine(comps ...component) bool {
+	for _, c := range comps {
+		switch c := c.(type) {
+		case *anchor:
+			if c.anchor == syntax.EmptyBeginLine {
+				return true
+			}
+			continue
+		case *group:
+			return beginsLine(c.compound)
+		case *compound:
+			return beginsLine(c.compound...)
+		case *alternation:
+			for _, alt := range c.alternatives {
+				if beginsLine(alt) {
+					return true
+				}
 			}
 		}
-		return s[i : i+skip], skip
+		return false
 	}
+	return false
+}
 
-	return "", 0
+// A literal is already solved, it only needs to be written out. Escapes such as
+// "\x41", "\n" and "\Q1+1\E" are already resolved by regexp/syntax. Under (?i), each
+// character is written in any one of its cases.
+func (l *literal) solve(s *solution, n int) error {
+	if err := s.fits(l, l.re, n); err != nil {
+		return err
+	}
+	e := s.take(l)
+	after := s.after
+	sets := l.sets()
+	rest := s.beforeCharacters(sets, after)
+	for i, set := range sets {
+		if e != nil && e.index == i {
+			set = e.set
+		}
+		s.after = rest[i+1]
+		if err := s.write(set); err != nil {
+			return err
+		}
+	}
+	s.after = after
+	return nil
 }
 
-// To solve a compound, first split it into its components, then solve each one, then rejoin.
-func (c *compound) solve() (string, error) {
-	splits := c.split()
+// The characters each character of the literal can be written as.
+func (l *literal) sets() []runeSet {
+	sets := make([]runeSet, 0, len(l.literal))
+	for _, r := range l.literal {
+		set := runeSet{r, r}
+		if l.re.Flags&syntax.FoldCase != 0 {
+			set = foldSet(r)
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
+// To solve a special, pick any character at all, other than a newline for ".".
+// Under (?s), a newline is as likely as anything in the all characters set.
+func (sp *special) solve(s *solution, n int) error {
+	if err := s.fits(sp, sp.re, n); err != nil {
+		return err
+	}
+	if e := s.take(sp); e != nil {
+		return s.write(e.set)
+	}
+	if sp.special == syntax.OpAnyCharNotNL {
+		return s.write(runeSet{0, '\n' - 1, '\n' + 1, unicode.MaxRune})
+	}
+
+	return s.write(runeSet{0, unicode.MaxRune}, s.rr.allCharactersSet.union(newlineSet))
+}
+
+// To solve a range, pick one of its characters at random. The range is already
+// expanded by regexp/syntax, so "[A-D]" holds every character from A to D, and
+// "[^abc]" holds every character but a, b and c. Those that are also in the all
+// characters set are preferred, so a negated range picks from the all characters
+// set, less the characters it excludes.
+func (r *regRange) solve(s *solution, n int) error {
+	if err := s.fits(r, r.re, n); err != nil {
+		return err
+	}
+	if e := s.take(r); e != nil {
+		return s.write(e.set)
+	}
+	if r.regRange.size() == 0 {
+		return &UnsatisfiableError{re: r.re}
+	}
+	if err := s.write(r.regRange, s.wantedRanges(r)...); err != nil {
+		return err
+	}
+	s.hit(r, int(s.last()))
+	return nil
+}
 
-	resPieces := []string{}
-	for _, split := range splits {
-		resPiece, err := split.solve()
+// A group is a compound, recursively solve its internal compound. If the group is
+// named, and pinned to a value, write that value instead.
+func (g *group) solve(s *solution, n int) error {
+	p, ok := s.pins[g.re.Name]
+	if !ok {
+		return g.compound.solve(s, n)
+	}
+
+	if p.valid == nil {
+		valid, err := regexp.Compile(`\A(?:` + g.re.Sub[0].String() + `)\z`)
 		if err != nil {
-			return "", err
+			return errors.Wrapf(err, "unable to check values of capture group %s", g.re.Name)
 		}
-		resPieces = append(resPieces, resPiece)
+		p.valid = valid
 	}
 
-	return strings.Join(resPieces, ""), nil
+	value := p.value()
+	if !p.valid.MatchString(value) {
+		return errors.Errorf("value `%s` for capture group %s does not match %s", value, g.re.Name, g.re.Sub[0])
+	}
+	if n != anyLength && utf8.RuneCountInString(value) != n {
+		return &UnsatisfiableError{re: g.re}
+	}
+	sets := []runeSet{}
+	for _, r := range value {
+		sets = append(sets, runeSet{r, r})
+	}
+	after := s.after
+	rest := s.beforeCharacters(sets, after)
+	for i, set := range sets {
+		s.after = rest[i+1]
+		if err := s.write(set); err != nil {
+			return err
+		}
+	}
+	s.after = after
+	return nil
 }
 
-// To solve a literal, examine its modifier, and repeat it that many times.
-func (l *literal) solve() (string, error) {
-	repeats, err := l.rr.repeats(l.modifier)
+// To solve an alternation, select one of its alternatives and solve only that one.
+func (a *alternation) solve(s *solution, n int) error {
+	i, err := s.branch(a, n)
 	if err != nil {
-		return "", err
+		return err
 	}
+	s.hit(a, i)
+	return a.alternatives[i].solve(s, n)
+}
 
-	// if this is an escaped special char, cut off the escape character
-	char := l.literal
-	if char[0] == '\\' {
-		char = string(l.literal[1])
+// An anchor writes nothing, but leaves a constraint on the characters before and
+// after it for the next write to satisfy.
+func (a *anchor) solve(s *solution, n int) error {
+	if err := s.fits(a, a.re, n); err != nil {
+		return err
 	}
-	return strings.Repeat(char, repeats), nil
+	s.pending |= a.anchor
+	s.anchor = a.re
+	return nil
 }
 
-// To solve a special, determine which special it is, then apply its special logic.
-func (s *special) solve() (string, error) {
-	repeats, err := s.rr.repeats(s.modifier)
-	if err != nil {
-		return "", err
+// A repetition solves its repeated component the number of times dictated by its
+// minimum and maximum, picking from the numbers of repeats the rest of the regex can
+// still be solved after. To solve to a given length, it picks a number of repeats that
+// can add up to that length, then shares the length out between them.
+func (r *repetition) solve(s *solution, n int) error {
+	counts := []int{}
+	for k := r.min; k <= s.rr.bound(r.min, r.max); k++ {
+		if n == anyLength || s.power(r, k).Bit(n) == 1 {
+			counts = append(counts, k)
+		}
+	}
+	if len(counts) == 0 {
+		return &UnsatisfiableError{re: r.re}
 	}
 
-	resPieces := []string{}
+	if n == anyLength {
+		if e := s.take(r); e != nil {
+			counts = []int{e.repeats}
+		}
+	}
+	after := s.after
+	rest := s.repeatsBefore(r, s.only(after, s.left-n, n), counts[len(counts)-1])
+	counts = s.liveRepeats(counts, rest)
+	if want := s.wanted(r, counts); len(want) > 0 {
+		counts = want
+	}
+	repeats := s.pick(counts)
+	s.hit(r, repeats)
 	for i := 0; i < repeats; i++ {
-		resPiece := ""
-		switch s.special {
-		case ".":
-			resPiece = string(s.rr.allCharactersSet[rand.Intn(len(s.rr.allCharactersSet))])
-		case "\\d":
-			resPiece = string(Digits()[rand.Intn(len(Digits()))])
-		default:
-			return "", errors.Errorf("cannot yet handle special character %s", s.special)
+		repN := anyLength
+		if n != anyLength {
+			repN = s.split(r.repeated, rest[repeats-i-1], s.power(r, repeats-i-1), n)
+			n -= repN
+		}
+		s.after = rest[repeats-i-1]
+		if err := r.repeated.solve(s, repN); err != nil {
+			return err
 		}
-
-		resPieces = append(resPieces, resPiece)
 	}
-	return strings.Join(resPieces, ""), nil
+	s.after = after
+	return nil
 }
 
-// To solve a range, determine:
-//   1. Is it a range?
-//      - ranges, use ASCII modulated characters.
-//      - pick one at random
-//   2. If not, simple split into component literals
-//      - pick one at random
-func (r *regRange) solve() (string, error) {
-	repeats, err := r.rr.repeats(r.modifier)
-	if err != nil {
-		return "", err
+// The most repeats a repetition can have, capping unbounded ones.
+func (rr *RegexReverser) bound(min, max int) int {
+	if max != -1 {
+		return max
+	}
+	if min > rr.maxRepeats {
+		return min
 	}
+	return rr.maxRepeats
+}
 
-	resPieces := []string{}
-	for i := 0; i < repeats; i++ {
-		negated := false
-		if r.regRange[0] == '^' {
-			negated = true
-		}
-		currentSet := []byte{}
-		// first extract and solve ranges
-		escaped := false
-		for j := 0; j < len(r.regRange); j++ {
-			if escaped {
-				if escaped {
-					escaped = false
-					continue
-				}
+// Picks which alternative of an alternation to solve, using the configured
+// BranchSelector if there is one.
+func (s *solution) branch(a *alternation, n int) (int, error) {
+	re := a.re
+	if n != anyLength {
+		return s.branchLength(a, n)
+	}
+	all := make([]int, len(a.alternatives))
+	for i := range all {
+		all[i] = i
+	}
+	live := s.liveAlternatives(a, all, anyLength)
+	if want := s.wanted(a, live); len(want) > 0 {
+		return s.pick(want), nil
+	}
+	if s.rr.branchSelector == nil {
+		if s.rr.strategy != Random {
+			return s.byLength(a, live), nil
+		}
+		return live[s.rnd.Intn(len(live))], nil
+	}
 
-				charJ := r.regRange[j]
-				if charJ == '\\' {
-					escaped = true
-					continue
-				}
+	alternatives := append([]string(nil), a.written...)
+	i := s.rr.branchSelector(alternatives)
+	if i < 0 || i >= len(alternatives) {
+		return 0, errors.Errorf("BranchSelector chose alternative %d of %s, which only has %d", i, re, len(alternatives))
+	}
+	return i, nil
+}
 
-				if charJ == '-' {
-					// add every character in the set
-					// slice the solvable string to remove the set from it
+var (
+	newlineSet = runeSet{'\n', '\n'}
+	wordSet    = byteSet(Word())
+	otherSet   = wordSet.union(newlineSet).complement()
+	graphicals = byteSet(Graphical())
+
+	// The cases of ASCII letters that are outside of ASCII, the Kelvin sign and the
+	// long s, which regexp/syntax adds to "k" and "s" under (?i).
+	foreignCases = func() runeSet {
+		rs := []rune{}
+		for r := rune(0); r < utf8.RuneSelf; r++ {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				if f >= utf8.RuneSelf {
+					rs = append(rs, f)
 				}
 			}
 		}
+		return runeList(rs)
+	}()
+)
 
-		// then, extract literals
-		for j := 0; j < len(r.regRange); j++ {
-			char := r.regRange[j]
-			currentSet = append(currentSet, char)
-		}
-
-		selectedSet := currentSet
-		if negated {
-			// chose from anything in the "all" set not in the current set
-			selectedSet = []byte{}
-			for j := 0; j < len(r.rr.allCharactersSet); j++ {
-				search := r.rr.allCharactersSet[j]
-				found := false
-				for k := 0; k < len(currentSet); k++ {
-					if currentSet[k] == search {
-						found = true
-						break
-					}
-				}
-				if !found {
-					selectedSet = append(selectedSet, search)
-				}
+// Sets with more characters than this, such as "[^a]", "\D" or "\pL", are narrowed to
+// the all characters set. Smaller sets are written out in full.
+const largeSet = 1 << 16
+
+// Every case of a character, such as "k", "K" and the Kelvin sign for "k".
+func foldSet(r rune) runeSet {
+	folds := []rune{r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		folds = append(folds, f)
+	}
+	return runeList(folds)
+}
+
+// Writes one character from the set, satisfying any pending anchors, and if it can,
+// leaving the rest of the regex solvable. Characters in the preferred set are picked if
+// possible, otherwise the set is narrowed the way narrowing narrows it.
+func (s *solution) write(set runeSet, preferred ...runeSet) error {
+	set = set.intersect(validRunes)
+	if s.newline && set.contains('\n') {
+		set = newlineSet
+	}
+	s.newline = false
+
+	if mask := s.live(); mask != allKinds {
+		if live := set.intersect(kindMasks[mask]); s.writable(live) {
+			set = live
+		}
+	}
+
+	allowed := s.allowed(s.last())
+	if set.intersect(allowed).size() == 0 && s.out.Len() == 0 {
+		// Nothing has been written yet, so any character that helps to satisfy
+		// the pending anchors can be written ahead of the match.
+		for _, lead := range []rune{s.choose(wordSet), s.choose(otherSet), '\n'} {
+			if set.intersect(s.allowed(lead)).size() > 0 {
+				s.out.WriteRune(lead)
+				allowed = s.allowed(lead)
+				break
 			}
 		}
+	}
+	if set.intersect(allowed).size() == 0 {
+		return &UnsatisfiableError{re: s.anchor}
+	}
+
+	s.out.WriteRune(s.pickRune(s.rr.pickable(set, allowed, preferred...)))
+	s.pending = 0
+	s.left--
+	return nil
+}
+
+// Once every component is solved, any pending anchors must be satisfied by the end
+// of the string. If they aren't, a character after the match can satisfy them, so
+// long as that doesn't take the string past its Length.
+func (s *solution) finish() error {
+	prev := s.last()
+	if s.pending != 0 && !s.satisfies(prev, -1) {
+		if err := s.write(s.allowed(prev)); err != nil {
+			return err
+		}
+	}
 
-		resPieces = append(resPieces, string(selectedSet[rand.Intn(len(selectedSet))]))
+	if n := utf8.RuneCountInString(s.out.String()); s.rr.lengthSet && (n < s.rr.minLength || n > s.rr.maxLength) {
+		return &UnsatisfiableError{re: s.anchor}
 	}
-	return strings.Join(resPieces, ""), nil
+	return nil
 }
 
-// A group is a compound, recursively solve its internal compound, the number of times
-// dictated by the modifier.
-func (g *group) solve() (string, error) {
-	repeats, err := g.compound.rr.repeats(g.modifier)
-	if err != nil {
-		return "", err
+// The last character written, or -1 at the start of the string.
+func (s *solution) last() rune {
+	if s.out.Len() == 0 {
+		return -1
 	}
+	r, _ := utf8.DecodeLastRuneInString(s.out.String())
+	return r
+}
 
-	res := []string{}
-	for i := 0; i < repeats; i++ {
-		solved, err := g.compound.solve()
-		if err != nil {
-			return "", err
+// Reports whether the pending anchors are satisfied between prev and next.
+func (s *solution) satisfies(prev, next rune) bool {
+	return s.pending&^syntax.EmptyOpContext(prev, next) == 0
+}
+
+// Every character that can be written after prev without breaking a pending anchor.
+func (s *solution) allowed(prev rune) runeSet {
+	allowed := runeSet{}
+	for _, kind := range []runeSet{newlineSet, wordSet, otherSet} {
+		if s.satisfies(prev, kind[0]) {
+			allowed = allowed.union(kind)
 		}
-		res = append(res, solved)
-	}
-
-	return strings.Join(res, ""), nil
-}
-
-func (rr *RegexReverser) repeats(modifier string) (int, error) {
-	var repeats int
-	switch modifier {
-	case "?":
-		repeats = rand.Intn(2)
-	case "*":
-		repeats = rand.Intn(rr.maxRepeats + 1)
-	case "+":
-		repeats = rand.Intn(rr.maxRepeats) + 1
-	case "":
-		repeats = 1
-	default:
-		if modifier[0] == '{' && modifier[len(modifier)-1] == '}' {
-			splits := strings.Split(modifier[1:len(modifier)-1], ",")
-			if len(splits) == 1 {
-				repeat64, err := strconv.ParseInt(splits[0], 0, 0)
-				if err != nil {
-					return 0, errors.Wrap(err, "invalid count modifier")
-				}
-				return int(repeat64), nil
-			}
+	}
+	return allowed
+}
 
-			if len(splits) != 2 {
-				return 0, errors.Errorf("invalid count modifier %s", modifier)
-			}
-			min, err := strconv.ParseInt(splits[0], 0, 0)
-			if err != nil {
-				return 0, errors.Wrap(err, "invalid count modifier")
-			}
-			max, err := strconv.ParseInt(splits[1], 0, 0)
-			if err != nil {
-				return 0, errors.Wrap(err, "invalid count modifier")
-			}
-			repeats = rand.Intn(int(max)-int(min)) + int(min)
-		} else {
-			return 0, errors.Errorf("regrev doesn't know how to handle that modifier %s", modifier)
+// Picks a character from the set, as narrowed by effective.
+func (s *solution) choose(set runeSet, preferred ...runeSet) rune {
+	return s.pickRune(s.rr.effective(set, preferred...))
+}
+
+// Picks a character at random from the set. The Shortest strategy picks the lowest
+// character instead, and the Longest strategy the highest, or on retries, sometimes
+// one just past it.
+func (s *solution) pickRune(set runeSet) rune {
+	switch s.rr.strategy {
+	case Shortest:
+		return set.nth(s.rank(set.size()))
+	case Longest:
+		return set.nth(set.size() - 1 - s.rank(set.size()))
+	}
+	return set.nth(s.rnd.Intn(set.size()))
+}
+
+// Narrows a set to the characters regrev picks from, preferring characters in the
+// preferred sets, then those narrowing prefers for the set.
+func (rr *RegexReverser) effective(set runeSet, preferred ...runeSet) runeSet {
+	preferred = append(preferred, rr.narrowing(set)...)
+	for _, p := range preferred {
+		if p := set.intersect(p); p.size() > 0 {
+			return p
 		}
 	}
+	return set
+}
 
-	return repeats, nil
+// The characters write picks from a set, once those that would break the pending
+// anchors, the ones outside of allowed, are taken out. The set is still narrowed by
+// what it is as a whole, so "\b[^a]" at the start of a string picks from the all
+// characters set, same as "[^a]" does anywhere else.
+func (rr *RegexReverser) pickable(set, allowed runeSet, preferred ...runeSet) runeSet {
+	return rr.effective(set.intersect(allowed), append(preferred, rr.narrowing(set)...)...)
+}
+
+// The characters regrev prefers to pick from a set. Large sets prefer the all
+// characters set, then visible ASCII characters, then the whitespace set. This way
+// "\S", "\W" and "\D" pick from the all characters set, less the characters they
+// exclude. Smaller sets, such as "[a-z!]", "\d" or "\s", are the characters the regex
+// lists, so every one of them is picked, other than whitespace left out of the
+// whitespace set, and the cases of ASCII letters that (?i) adds from outside of ASCII.
+func (rr *RegexReverser) narrowing(set runeSet) []runeSet {
+	if set.size() > largeSet {
+		return []runeSet{rr.allCharactersSet, graphicals, byteSet(rr.whitespaceSet)}
+	}
+	excluded := byteSet(Whitespace()).intersect(byteSet(rr.whitespaceSet).complement())
+	return []runeSet{excluded.union(foreignCases).complement()}
 }
@@ -2,7 +2,10 @@ package regrev_test
 
 import (
 	"math/rand"
+	"reflect"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,9 +13,10 @@ import (
 )
 
 func TestRegexReverse(t *testing.T) {
-	rand.Seed(time.Now().UnixNano() / int64(time.Millisecond))
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
 
-	rr, err := regrev.NewRegexReverser()
+	rr, err := regrev.NewRegexReverser(regrev.Seed(seed))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -73,6 +77,78 @@ func TestRegexReverse(t *testing.T) {
 			Name: "Make me an ip address! Special characters with a purpose",
 			Reg:  regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}`),
 		},
+		{
+			Name: "Nested groups are parsed the way Go parses them",
+			Reg:  regexp.MustCompile(`((a)b)+(c(d(e)?)f)`),
+		},
+		{
+			Name: "Exact counts, and counts on groups",
+			Reg:  regexp.MustCompile(`a{3}(bc){3,3}[xyz]{2,}`),
+		},
+		{
+			Name: "Escaped reserved characters are literals",
+			Reg:  regexp.MustCompile(`\(\[\{\}\]\)\.\*\+\?\\`),
+		},
+		{
+			Name: "Alternation at the top level",
+			Reg:  regexp.MustCompile(`cat|dog|bird+`),
+		},
+		{
+			Name: "Alternations inside of groups, nested",
+			Reg:  regexp.MustCompile(`(GET|POST|PUT) /api/(v1|v2|(beta|alpha)[0-9])/.*`),
+		},
+		{
+			Name: "Anchors at the edges of the string",
+			Reg:  regexp.MustCompile(`^abc$|\Adef\z`),
+		},
+		{
+			Name: "Line anchors need newlines between them",
+			Reg:  regexp.MustCompile(`(?m)^[a-z]+$\s^[0-9]+$`),
+		},
+		{
+			Name: "A newline is the only way to start a line mid-string",
+			Reg:  regexp.MustCompile(`(?m)x\s^y`),
+		},
+		{
+			Name: "Word boundaries",
+			Reg:  regexp.MustCompile(`\bfoo\b.\bbar\b`),
+		},
+		{
+			Name: "Not word boundaries",
+			Reg:  regexp.MustCompile(`\B-\B-a\Bb\B`),
+		},
+		{
+			Name: "A character can be written ahead of the match to satisfy a boundary",
+			Reg:  regexp.MustCompile(`\Bx`),
+		},
+		{
+			Name: "Boundaries with nothing around them",
+			Reg:  regexp.MustCompile(`\b`),
+		},
+		{
+			Name: "Case insensitive literals and ranges",
+			Reg:  regexp.MustCompile(`(?i)hello [a-c]+ world`),
+		},
+		{
+			Name: "Scoped flags only apply to their group",
+			Reg:  regexp.MustCompile(`abc(?i:def)ghi(?i)jkl(?-i)mno`),
+		},
+		{
+			Name: "Dot matches newline under (?s)",
+			Reg:  regexp.MustCompile(`(?s)a.{5}b`),
+		},
+		{
+			Name: "Ungreedy and multiline flags together",
+			Reg:  regexp.MustCompile(`(?mU)^a+b*?$`),
+		},
+		{
+			Name: "Non-capturing groups",
+			Reg:  regexp.MustCompile(`(?:ab)+(?:c|d)?`),
+		},
+		{
+			Name: "Named capture groups",
+			Reg:  regexp.MustCompile(`(?P<year>\d{4})-(?P<month>\d{2})`),
+		},
 	}
 
 	for _, tc := range cases {
@@ -94,3 +170,516 @@ func TestRegexReverse(t *testing.T) {
 // TODO: FUZZ TESTERRRRRRR
 // This seems like the kind of project that would really benefit from this. Generate many many valid regexps
 // Throw them in, see if they produce a matching string.
+
+func TestBranchSelector(t *testing.T) {
+	offered := [][]string{}
+	last := func(alternatives []string) int {
+		offered = append(offered, alternatives)
+		return len(alternatives) - 1
+	}
+	rr, err := regrev.NewRegexReverser(regrev.BranchSelector(last))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rr.Reverse(regexp.MustCompile(`(GET|POST|PUT) /api/(v1|v2)/(?i:a|b)/(\p{Greek}|\d)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^PUT /api/v2/[bB]/[0-9]$`).MatchString(got) {
+		t.Errorf("expected the last branch of every alternation, got `%s`", got)
+	}
+	expected := [][]string{{"GET", "POST", "PUT"}, {"v1", "v2"}, {"a", "b"}, {`\p{Greek}`, `\d`}}
+	if !reflect.DeepEqual(offered, expected) {
+		t.Errorf("expected the alternatives as written, %v, got %v", expected, offered)
+	}
+
+	outOfRange := func(alternatives []string) int {
+		return len(alternatives)
+	}
+	rr, err = regrev.NewRegexReverser(regrev.BranchSelector(outOfRange))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rr.Reverse(regexp.MustCompile(`cat|dog`)); err == nil {
+		t.Error("expected an error when BranchSelector picks a branch that doesn't exist")
+	}
+}
+
+func TestNewlineBeforeLine(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Retries(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`(?ms)a.^b`),
+		regexp.MustCompile(`(?ms)a.(^b)`),
+		regexp.MustCompile(`(?ms)a(.)^b`),
+		regexp.MustCompile(`(?ms)a(?:[a-z\n])(?:\b^b)`),
+		regexp.MustCompile(`(?ms)a.(^b|^c)`),
+	}
+	for _, reg := range regs {
+		for i := 0; i < 200; i++ {
+			got, err := rr.Reverse(reg)
+			if err != nil {
+				t.Fatalf("expected %s to be solved without retrying, got %v", reg, err)
+			}
+			if !strings.HasPrefix(got, "a\n") {
+				t.Errorf("expected a newline before the start of the line in %s, got `%q`", reg, got)
+			}
+		}
+	}
+}
+
+func TestUnsatisfiable(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`a^b`),
+		regexp.MustCompile(`a$b`),
+		regexp.MustCompile(`a\bb`),
+		regexp.MustCompile(`(?m)a$b`),
+		regexp.MustCompile(`-\B-\b-`),
+	}
+	for _, reg := range regs {
+		if got, err := rr.Reverse(reg); err == nil {
+			t.Errorf("expected %s to be unsatisfiable, got `%s`", reg, got)
+		}
+	}
+}
+
+func TestUnsatisfiableError(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg    *regexp.Regexp
+		Expr   string
+		Offset int
+		End    int
+	}{
+		{regexp.MustCompile(`(?i)(?P<a>foo)\bbar`), `\b`, 14, 16},
+		{regexp.MustCompile(`x[^\x00-\x{10FFFF}]`), `[^\x00-\x{10FFFF}]`, 1, 19},
+		{regexp.MustCompile(`\d[^\d\D]`), `[^\d\D]`, 2, 9},
+		{regexp.MustCompile(`\w+^`), `^`, 3, 4},
+		{regexp.MustCompile(`(?m)^a$b`), `$`, 6, 7},
+		{regexp.MustCompile(`\Qa+\E\b\Q+\E`), `\b`, 6, 8},
+		{regexp.MustCompile(strings.Repeat(`(ab|[c-e]d?)+`, 60) + `x\ba`), `\b`, 781, 783},
+	}
+	for _, tc := range cases {
+		_, err := rr.Reverse(tc.Reg)
+		unsatisfiable, ok := err.(*regrev.UnsatisfiableError)
+		if !ok {
+			t.Errorf("expected an UnsatisfiableError for %s, got %v", tc.Reg, err)
+			continue
+		}
+		if unsatisfiable.Pattern != tc.Reg.String() || unsatisfiable.Expr != tc.Expr ||
+			unsatisfiable.Offset != tc.Offset || unsatisfiable.End != tc.End {
+			t.Errorf("expected %s at offsets %d to %d of %s, got %s at offsets %d to %d of %s", tc.Expr, tc.Offset, tc.End, tc.Reg,
+				unsatisfiable.Expr, unsatisfiable.Offset, unsatisfiable.End, unsatisfiable.Pattern)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.AllCharacterSet([]byte{'a'}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		got, err := rr.Reverse(regexp.MustCompile(`(?i:x)y(?s:.)`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[got] = true
+	}
+
+	expected := []string{"xya", "Xya", "xy\n", "Xy\n"}
+	for _, e := range expected {
+		if !seen[e] {
+			t.Errorf("expected to see `%q` after 100 tries, only saw %v", e, seen)
+		}
+	}
+	if len(seen) != len(expected) {
+		t.Errorf("expected only %v, saw %v", expected, seen)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.AllCharacterSet([]byte("abcxyz019_-")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Expected string
+	}{
+		{regexp.MustCompile(`[A-D]`), "ABCD"},
+		{regexp.MustCompile(`[*\-\\]`), `*-\`},
+		{regexp.MustCompile(`[*-,]`), "*+,"},
+		{regexp.MustCompile(`[-a]`), "-a"},
+		{regexp.MustCompile(`[a-]`), "-a"},
+		{regexp.MustCompile(`[]a]`), "]a"},
+		{regexp.MustCompile(`[\d_]`), "0123456789_"},
+		{regexp.MustCompile(`[^abc]`), "xyz019_-"},
+		{regexp.MustCompile(`[^\d\-]`), "abcxyz_"},
+		{regexp.MustCompile(`[^a-z0-9_-]`), "!\"#$%&'()*+,./:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^`{|}~ "},
+	}
+
+	for _, tc := range cases {
+		for i := 0; i < 50; i++ {
+			got, err := rr.Reverse(tc.Reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len([]rune(got)) != 1 || !strings.Contains(tc.Expected, got) {
+				t.Errorf("expected %s to produce one of `%s`, got `%s`", tc.Reg, tc.Expected, got)
+			}
+		}
+	}
+}
+
+func TestClassCharacters(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Expected string
+	}{
+		{regexp.MustCompile(`[\d_]`), "0123456789_"},
+		{regexp.MustCompile(`[a-c!]`), "abc!"},
+		{regexp.MustCompile(`(?i)[k!]`), "kK!"},
+	}
+
+	for _, tc := range cases {
+		seen := map[string]bool{}
+		for i := 0; i < 200; i++ {
+			got, err := rr.Reverse(tc.Reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[got] = true
+		}
+		for _, e := range tc.Expected {
+			if !seen[string(e)] {
+				t.Errorf("expected %s to produce `%c` after 200 tries, only saw %v", tc.Reg, e, seen)
+			}
+		}
+		for got := range seen {
+			if len([]rune(got)) != 1 || !strings.Contains(tc.Expected, got) {
+				t.Errorf("expected %s to produce one of `%s`, got `%s`", tc.Reg, tc.Expected, got)
+			}
+		}
+	}
+}
+
+func TestPerlClasses(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.SaneWhitespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Expected string
+	}{
+		{regexp.MustCompile(`\s`), " \t\n"},
+		{regexp.MustCompile(`[\s\d]`), " \t\n0123456789"},
+		{regexp.MustCompile(`\S`), string(regrev.AllCharacters())},
+		{regexp.MustCompile(`\w`), string(regrev.Word())},
+		{regexp.MustCompile(`\D`), string(regrev.Alpha())},
+		{regexp.MustCompile(`[^\s\D]`), string(regrev.Digits())},
+		{regexp.MustCompile(`\W`), strings.Replace(string(regrev.Punctuation()), "_", "", 1)},
+	}
+
+	for _, tc := range cases {
+		for i := 0; i < 50; i++ {
+			got, err := rr.Reverse(tc.Reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len([]rune(got)) != 1 || !strings.Contains(tc.Expected, got) {
+				t.Errorf("expected %s to produce one of %q, got %q", tc.Reg, tc.Expected, got)
+			}
+		}
+	}
+}
+
+func TestQuantifiers(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.MaxRepeats(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg     *regexp.Regexp
+		Lengths []int
+	}{
+		{regexp.MustCompile(`^a?$`), []int{0, 1}},
+		{regexp.MustCompile(`^a*$`), []int{0, 1, 2, 3, 4}},
+		{regexp.MustCompile(`^a+$`), []int{1, 2, 3, 4}},
+		{regexp.MustCompile(`^a{3}$`), []int{3}},
+		{regexp.MustCompile(`^a{3,3}$`), []int{3}},
+		{regexp.MustCompile(`^a{2,5}$`), []int{2, 3, 4, 5}},
+		{regexp.MustCompile(`^a{2,}$`), []int{2, 3, 4}},
+		{regexp.MustCompile(`^a{7,}$`), []int{7}},
+		{regexp.MustCompile(`^a{0}$`), []int{0}},
+		{regexp.MustCompile(`^a??$`), []int{0, 1}},
+		{regexp.MustCompile(`^a*?$`), []int{0, 1, 2, 3, 4}},
+		{regexp.MustCompile(`^a+?$`), []int{1, 2, 3, 4}},
+		{regexp.MustCompile(`^a{2,3}?$`), []int{2, 3}},
+		{regexp.MustCompile(`^(?U)a{1,2}$`), []int{1, 2}},
+	}
+
+	for _, tc := range cases {
+		seen := map[int]bool{}
+		for i := 0; i < 200; i++ {
+			got, err := rr.Reverse(tc.Reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[len(got)] = true
+		}
+
+		for _, l := range tc.Lengths {
+			if !seen[l] {
+				t.Errorf("expected %s to produce a string of length %d", tc.Reg, l)
+			}
+			delete(seen, l)
+		}
+		for l := range seen {
+			t.Errorf("expected %s to never produce a string of length %d", tc.Reg, l)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	rr, err := regrev.NewRegexReverser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Expected string
+	}{
+		{regexp.MustCompile(`\x41\x{263A}\x{1F600}`), "A\u263a\U0001f600"},
+		{regexp.MustCompile(`\a\f\t\n\r\v`), "\a\f\t\n\r\v"},
+		{regexp.MustCompile(`\101\060\0`), "A0\x00"},
+		{regexp.MustCompile(`\Q1+1\E=\Q[2]`), "1+1=[2]"},
+		{regexp.MustCompile(`\.\*\+\?\(\)\[\]\{\}\|\^\$\\`), `.*+?()[]{}|^$\`},
+		{regexp.MustCompile(`[\x{263A}][\n][\-]`), "\u263a\n-"},
+	}
+
+	for _, tc := range cases {
+		got, err := rr.Reverse(tc.Reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.Expected {
+			t.Errorf("expected %s to produce %q, got %q", tc.Reg, tc.Expected, got)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`(GET|POST|PUT) /api/(v1|beta)/.*`),
+		regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`),
+		regexp.MustCompile(`(?i)[^a-z]+\s\w*`),
+	}
+	reverse := func(rr *regrev.RegexReverser) []string {
+		results := []string{}
+		for i := 0; i < 10; i++ {
+			for _, reg := range regs {
+				got, err := rr.Reverse(reg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				results = append(results, got)
+			}
+		}
+		return results
+	}
+
+	first, err := regrev.NewRegexReverser(regrev.Seed(1234))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := regrev.NewRegexReverser(regrev.Seed(1234))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := regrev.NewRegexReverser(regrev.Seed(4321))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := reverse(first)
+	if got := reverse(second); !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected the same seed to produce the same strings, got %q and %q", expected, got)
+	}
+	if got := reverse(other); reflect.DeepEqual(expected, got) {
+		t.Errorf("expected different seeds to produce different strings, got %q twice", got)
+	}
+
+	first, err = regrev.NewRegexReverser(regrev.RandSource(rand.NewSource(1234)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err = regrev.NewRegexReverser(regrev.RandSource(rand.NewSource(1234)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := reverse(first), reverse(second); !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected the same source to produce the same strings, got %q and %q", expected, got)
+	}
+}
+
+func TestConcurrentReverse(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(1234))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`(?P<method>GET|POST|PUT) /api/(v1|beta)/\w+\?id=\d{1,8}`)
+	var wg sync.WaitGroup
+	results := make(chan string, 8*50)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				got, err := rr.Reverse(reg)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := rr.ReverseResult(reg); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := rr.ReverseWith(reg, map[string]string{"method": "GET"}); err != nil {
+					t.Error(err)
+					return
+				}
+				results <- got
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for got := range results {
+		if !reg.MatchString(got) {
+			t.Errorf("expected reversed string `%s` to match regexp %s", got, reg)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pinned value skips the boundary inside its group, so only the strings that
+	// leave out the letter after it match.
+	reg := regexp.MustCompile(`^(?P<id>\d+\b)[a-z]?$`)
+	for i := 0; i < 20; i++ {
+		str, err := rr.ReverseWith(reg, map[string]string{"id": "12"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if str != "12" {
+			t.Errorf("expected `12` for %s, got `%s`", reg, str)
+		}
+	}
+
+	if _, err := regrev.NewRegexReverser(regrev.Retries(-1)); err == nil {
+		t.Error("expected an error for a negative number of retries")
+	}
+}
+
+func TestAnchorsAheadOfTheirChoices(t *testing.T) {
+	// Each of these has choices, such as taking `a\b` on a repeat that isn't the
+	// last, that leave the anchors after them impossible to satisfy, so they have to
+	// be solved on the first attempt without making any of them.
+	regs := []*regexp.Regexp{
+		regexp.MustCompile(`(?:\w+\b ?)+`),
+		regexp.MustCompile(`(a\b|b)+`),
+		regexp.MustCompile(`(x$|y)+`),
+		regexp.MustCompile(`(?m)(\w+$\n?)+`),
+		regexp.MustCompile(`(?m)(^\w+$\n?)+`),
+		regexp.MustCompile(`(\bfoo\b|bar)+`),
+		regexp.MustCompile(`(?:(?:(\s*|a{2,})+\b(?m:^)*)^?\Qa+\E\n){2}`),
+	}
+	configs := map[string][]func(*regrev.RegexReverser) error{
+		"Random":          {},
+		"Shortest":        {regrev.UseStrategy(regrev.Shortest)},
+		"Longest":         {regrev.UseStrategy(regrev.Longest)},
+		"Length":          {regrev.Length(3, 12)},
+		"Longest, Length": {regrev.UseStrategy(regrev.Longest), regrev.Length(3, 12)},
+	}
+
+	for name, config := range configs {
+		for seed := int64(0); seed < 5; seed++ {
+			rr, err := regrev.NewRegexReverser(append(config, regrev.Seed(seed), regrev.Retries(0))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, reg := range regs {
+				str, err := rr.Reverse(reg)
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+					continue
+				}
+				if !reg.MatchString(str) {
+					t.Errorf("%s: `%s` doesn't match %s", name, str, reg)
+				}
+			}
+		}
+	}
+}
+
+func TestErrNoMatch(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(42), regrev.Retries(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`(?P<id>\d+\b)[a-z]`)
+	values := map[string]string{"id": "12"}
+	_, err = rr.ReverseWith(reg, values)
+	noMatch, ok := err.(*regrev.ErrNoMatch)
+	if !ok {
+		t.Fatalf("expected an ErrNoMatch for %s, got %v", reg, err)
+	}
+	if noMatch.Pattern != reg.String() {
+		t.Errorf("expected the pattern %s, got %s", reg, noMatch.Pattern)
+	}
+	if reg.MatchString(noMatch.Candidate) || !regexp.MustCompile(`^12[a-z]$`).MatchString(noMatch.Candidate) {
+		t.Errorf("expected a candidate that almost matches %s, got `%s`", reg, noMatch.Candidate)
+	}
+
+	replay, err := regrev.NewRegexReverser(regrev.Seed(noMatch.Seed), regrev.Retries(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replay.ReverseWith(reg, values)
+	if replayed, ok := err.(*regrev.ErrNoMatch); !ok || *replayed != *noMatch {
+		t.Errorf("expected seed %d to replay %v, got %v", noMatch.Seed, noMatch, err)
+	}
+}
@@ -0,0 +1,194 @@
+package regrev
+
+import (
+	"sort"
+	"unicode"
+)
+
+// A runeSet is a sorted list of inclusive rune ranges, stored in pairs the same way
+// regexp/syntax stores the ranges of a character class: [lo0, hi0, lo1, hi1, ...].
+type runeSet []rune
+
+// Every rune that can be encoded as valid UTF-8, which leaves out the surrogate
+// halves used by UTF-16.
+var validRunes = runeSet{0, 0xd7ff, 0xe000, unicode.MaxRune}
+
+// Builds a runeSet out of a list of individual bytes, such as AllCharacters().
+func byteSet(bs []byte) runeSet {
+	rs := make([]rune, 0, len(bs))
+	for _, b := range bs {
+		rs = append(rs, rune(b))
+	}
+	return runeList(rs)
+}
+
+// Builds a runeSet out of a list of individual runes, in any order.
+func runeList(rs []rune) runeSet {
+	sorted := append([]rune{}, rs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	set := runeSet{}
+	for _, r := range sorted {
+		if len(set) > 0 && r <= set[len(set)-1]+1 {
+			if r > set[len(set)-1] {
+				set[len(set)-1] = r
+			}
+			continue
+		}
+		set = append(set, r, r)
+	}
+	return set
+}
+
+// Builds a runeSet out of a table from the unicode package.
+func tableSet(table *unicode.RangeTable) runeSet {
+	pairs := runeSet{}
+	add := func(lo, hi, stride rune) {
+		if stride == 1 {
+			pairs = append(pairs, lo, hi)
+			return
+		}
+		for r := lo; r <= hi; r += stride {
+			pairs = append(pairs, r, r)
+		}
+	}
+	for _, r16 := range table.R16 {
+		add(rune(r16.Lo), rune(r16.Hi), rune(r16.Stride))
+	}
+	for _, r32 := range table.R32 {
+		add(rune(r32.Lo), rune(r32.Hi), rune(r32.Stride))
+	}
+	return runeSet{}.union(pairs)
+}
+
+// The number of runes in the set.
+func (rs runeSet) size() int {
+	size := 0
+	for i := 0; i < len(rs); i += 2 {
+		size += int(rs[i+1]-rs[i]) + 1
+	}
+	return size
+}
+
+// The i'th rune of the set, counting up from the lowest rune.
+func (rs runeSet) nth(n int) rune {
+	for i := 0; i < len(rs); i += 2 {
+		width := int(rs[i+1]-rs[i]) + 1
+		if n < width {
+			return rs[i] + rune(n)
+		}
+		n -= width
+	}
+	panic("regrev: rune index out of range")
+}
+
+func (rs runeSet) contains(r rune) bool {
+	for i := 0; i < len(rs); i += 2 {
+		if r < rs[i] {
+			return false
+		}
+		if r <= rs[i+1] {
+			return true
+		}
+	}
+	return false
+}
+
+// The runes present in both sets.
+func (rs runeSet) intersect(other runeSet) runeSet {
+	result := runeSet{}
+	i, j := 0, 0
+	for i < len(rs) && j < len(other) {
+		lo, hi := rs[i], rs[i+1]
+		if other[j] > lo {
+			lo = other[j]
+		}
+		if other[j+1] < hi {
+			hi = other[j+1]
+		}
+		if lo <= hi {
+			result = append(result, lo, hi)
+		}
+
+		if rs[i+1] < other[j+1] {
+			i += 2
+		} else {
+			j += 2
+		}
+	}
+	return result
+}
+
+// The runes present in either set.
+func (rs runeSet) union(other runeSet) runeSet {
+	pairs := append(append(runeSet{}, rs...), other...)
+	sort.Sort(rangePairs(pairs))
+
+	result := runeSet{}
+	for i := 0; i < len(pairs); i += 2 {
+		lo, hi := pairs[i], pairs[i+1]
+		if len(result) > 0 && lo <= result[len(result)-1]+1 {
+			if hi > result[len(result)-1] {
+				result[len(result)-1] = hi
+			}
+			continue
+		}
+		result = append(result, lo, hi)
+	}
+	return result
+}
+
+// Every rune that is not in the set.
+func (rs runeSet) complement() runeSet {
+	result := runeSet{}
+	next := rune(0)
+	for i := 0; i < len(rs); i += 2 {
+		if rs[i] > next {
+			result = append(result, next, rs[i]-1)
+		}
+		next = rs[i+1] + 1
+	}
+	if next <= unicode.MaxRune {
+		result = append(result, next, unicode.MaxRune)
+	}
+	return result
+}
+
+// The lowest and highest rune of each range in the set, in order.
+func (rs runeSet) endpoints() []rune {
+	endpoints := []rune{}
+	for i := 0; i < len(rs); i += 2 {
+		endpoints = append(endpoints, rs[i])
+		if rs[i+1] != rs[i] {
+			endpoints = append(endpoints, rs[i+1])
+		}
+	}
+	return endpoints
+}
+
+// The valid runes just outside each range in the set, in order.
+func (rs runeSet) neighbours() []rune {
+	neighbours := []rune{}
+	for i := 0; i < len(rs); i += 2 {
+		for _, r := range []rune{rs[i] - 1, rs[i+1] + 1} {
+			if !validRunes.contains(r) || rs.contains(r) {
+				continue
+			}
+			if len(neighbours) > 0 && neighbours[len(neighbours)-1] == r {
+				continue
+			}
+			neighbours = append(neighbours, r)
+		}
+	}
+	return neighbours
+}
+
+// rangePairs sorts a runeSet whose pairs are out of order by their low rune.
+type rangePairs runeSet
+
+func (rp rangePairs) Len() int           { return len(rp) / 2 }
+func (rp rangePairs) Less(i, j int) bool { return rp[2*i] < rp[2*j] }
+func (rp rangePairs) Swap(i, j int) {
+	rp[2*i], rp[2*j] = rp[2*j], rp[2*i]
+	rp[2*i+1], rp[2*j+1] = rp[2*j+1], rp[2*i+1]
+}
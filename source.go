@@ -0,0 +1,312 @@
+package regrev
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// regexp/syntax factors alternations as it parses them, so `GET|POST|PUT` becomes
+// `GET|P(?:OST|UT)`, and `v1|v2` becomes `v[12]`. It also forgets where in the pattern
+// each part of the regex was written. To solve alternatives as they are written, and
+// to know where each part is written, regrev first wraps every alternative, every
+// atom and every repetition of the pattern in a capture group of its own, named for
+// where it is written. regexp/syntax never factors capture groups, and once the
+// pattern is parsed, the groups are taken out again.
+
+// A source rewrites a pattern, wrapping each of its parts in a capture group named
+// with prefix and a number, which indexes spans.
+type source struct {
+	pattern string
+	i       int
+	prefix  string
+	spans   []span
+}
+
+// A span is where a part of the regex is written in the pattern, from the byte offset
+// start up to end, or -1 and -1 for parts that aren't written anywhere.
+type span struct {
+	start, end int
+}
+
+// Parses the regex the same way regexp.Compile does, but without factoring its
+// alternations. Also returns where in the pattern each node of the parsed regex is
+// written.
+func parseSource(reg *regexp.Regexp) (*syntax.Regexp, map[*syntax.Regexp]span, error) {
+	src := &source{pattern: reg.String(), prefix: "regrev_"}
+	for strings.Contains(src.pattern, src.prefix) {
+		src.prefix += "_"
+	}
+
+	re, err := syntax.Parse(src.alternation(), syntax.Perl)
+	if err != nil || src.i != len(src.pattern) {
+		// The pattern is one regexp/syntax could parse, so this can only be a gap
+		// in rewriting it. Parse it as it is, alternations factored.
+		re, err := parseRegexp(reg)
+		return re, map[*syntax.Regexp]span{}, err
+	}
+
+	positions := map[*syntax.Regexp]span{}
+	return src.unwrap(re, positions), positions, nil
+}
+
+// Wraps text in a capture group marking it as written from start up to end.
+func (src *source) wrap(text string, start, end int) string {
+	name := src.prefix + strconv.Itoa(len(src.spans))
+	src.spans = append(src.spans, span{start, end})
+	return "(?P<" + name + ">" + text + ")"
+}
+
+// Rewrites alternatives up to the end of the enclosing group. Flags set by "(?i)" and
+// the like last until the end of the group, so they are repeated at the start of
+// every alternative after the one they are set in.
+func (src *source) alternation() string {
+	var out strings.Builder
+	flags := ""
+	for {
+		start := src.i
+		var alt strings.Builder
+		alt.WriteString(flags)
+		for src.i < len(src.pattern) && src.pattern[src.i] != '|' && src.pattern[src.i] != ')' {
+			text, directive := src.item()
+			alt.WriteString(text)
+			if directive {
+				flags += text
+			}
+		}
+		out.WriteString(src.wrap(alt.String(), start, src.i))
+
+		if src.i >= len(src.pattern) || src.pattern[src.i] != '|' {
+			return out.String()
+		}
+		out.WriteByte('|')
+		src.i++
+	}
+}
+
+// Rewrites one atom, along with any repetition of it. Reports whether it was a flag
+// directive such as "(?i)" instead, which is left as it is.
+func (src *source) item() (string, bool) {
+	start := src.i
+	p := src.pattern
+	var atom string
+
+	switch {
+	case strings.HasPrefix(p[src.i:], `\Q`):
+		// Every character quoted by \Q...\E is an atom of its own, and only the
+		// last is repeated by a repetition after it.
+		src.i += 2
+		end := strings.Index(p[src.i:], `\E`)
+		quoted := p[src.i:]
+		if end >= 0 {
+			quoted = p[src.i : src.i+end]
+		}
+		var out strings.Builder
+		for quoted != "" {
+			_, size := utf8.DecodeRuneInString(quoted)
+			start = src.i
+			atom = regexp.QuoteMeta(quoted[:size])
+			src.i += size
+			quoted = quoted[size:]
+			if quoted != "" {
+				out.WriteString(src.wrap(atom, start, src.i))
+			}
+		}
+		atomEnd := src.i
+		if end >= 0 {
+			src.i += 2
+		}
+		if atom == "" {
+			return out.String(), false
+		}
+		return out.String() + src.repeated(atom, start, atomEnd), false
+	case p[src.i] == '(':
+		opener, directive := src.group()
+		if directive {
+			return opener, true
+		}
+		body := src.alternation()
+		src.i++ // the closing parenthesis
+		atom = opener + body + ")"
+	case p[src.i] == '[':
+		src.class()
+		atom = p[start:src.i]
+	case p[src.i] == '\\':
+		src.escape()
+		atom = p[start:src.i]
+	default:
+		_, size := utf8.DecodeRuneInString(p[src.i:])
+		src.i += size
+		atom = p[start:src.i]
+	}
+	return src.repeated(atom, start, src.i), false
+}
+
+// Wraps an atom written from start up to end, along with the repetition after it, if
+// any.
+func (src *source) repeated(atom string, start, end int) string {
+	atom = src.wrap(atom, start, end)
+	p := src.pattern
+	quantifier := src.i
+	switch {
+	case src.i < len(p) && strings.ContainsRune("*+?", rune(p[src.i])):
+		src.i++
+	case src.i < len(p) && p[src.i] == '{':
+		m := repeatCount.FindString(p[src.i:])
+		if m == "" {
+			return atom
+		}
+		src.i += len(m)
+	default:
+		return atom
+	}
+	if src.i < len(p) && p[src.i] == '?' {
+		src.i++
+	}
+	return src.wrap(atom+p[quantifier:src.i], start, src.i)
+}
+
+var repeatCount = regexp.MustCompile(`\A\{[0-9]+(?:,[0-9]*)?\}`)
+
+// Skips the opening of a group, returning it, and reports whether it is a flag
+// directive such as "(?i)" rather than a group.
+func (src *source) group() (string, bool) {
+	p := src.pattern
+	start := src.i
+	switch {
+	case strings.HasPrefix(p[src.i:], "(?P<"), strings.HasPrefix(p[src.i:], "(?<"):
+		src.i += strings.IndexByte(p[src.i:], '>') + 1
+		return p[start:src.i], false
+	case strings.HasPrefix(p[src.i:], "(?"):
+		end := strings.IndexAny(p[src.i:], ":)")
+		src.i += end + 1
+		return p[start:src.i], p[src.i-1] == ')'
+	}
+	src.i++
+	return p[start:src.i], false
+}
+
+// Skips a character class, such as "[^a-z]" or "[[:alpha:]\d]".
+func (src *source) class() {
+	p := src.pattern
+	src.i++
+	if src.i < len(p) && p[src.i] == '^' {
+		src.i++
+	}
+	// A closing bracket straight after the opening one is a character of the class.
+	if src.i < len(p) && p[src.i] == ']' {
+		src.i++
+	}
+	for src.i < len(p) && p[src.i] != ']' {
+		switch {
+		case strings.HasPrefix(p[src.i:], "[:"):
+			if end := strings.Index(p[src.i:], ":]"); end >= 0 {
+				src.i += end + 2
+				continue
+			}
+			src.i++
+		case p[src.i] == '\\':
+			src.escape()
+		default:
+			_, size := utf8.DecodeRuneInString(p[src.i:])
+			src.i += size
+		}
+	}
+	src.i++
+}
+
+// Skips an escape, such as "\d", "\x{263a}", "\pL" or "\101".
+func (src *source) escape() {
+	p := src.pattern
+	src.i++
+	if src.i >= len(p) {
+		return
+	}
+	c := p[src.i]
+	src.i++
+	switch {
+	case c >= '0' && c <= '7':
+		for n := 0; n < 2 && src.i < len(p) && p[src.i] >= '0' && p[src.i] <= '7'; n++ {
+			src.i++
+		}
+	case c == 'x' || c == 'p' || c == 'P':
+		if src.i < len(p) && p[src.i] == '{' {
+			src.i += strings.IndexByte(p[src.i:], '}') + 1
+		} else if c == 'x' {
+			src.i += 2
+		} else {
+			_, size := utf8.DecodeRuneInString(p[src.i:])
+			src.i += size
+		}
+	case c >= utf8.RuneSelf:
+		_, size := utf8.DecodeRuneInString(p[src.i-1:])
+		src.i += size - 1
+	}
+}
+
+// Takes the capture groups added by rewriting back out of the parsed regex, noting
+// where each part of it is written. Concatenations and literals split apart by the
+// groups are joined back together, the way regexp/syntax would have parsed them.
+func (src *source) unwrap(re *syntax.Regexp, positions map[*syntax.Regexp]span) *syntax.Regexp {
+	written := span{-1, -1}
+	for re.Op == syntax.OpCapture && strings.HasPrefix(re.Name, src.prefix) {
+		if written.start < 0 {
+			k, _ := strconv.Atoi(re.Name[len(src.prefix):])
+			written = src.spans[k]
+		}
+		re = re.Sub[0]
+	}
+
+	subs := make([]*syntax.Regexp, 0, len(re.Sub))
+	for _, sub := range re.Sub {
+		sub = src.unwrap(sub, positions)
+		if re.Op == syntax.OpConcat && sub.Op == syntax.OpConcat {
+			subs = append(subs, sub.Sub...)
+		} else {
+			subs = append(subs, sub)
+		}
+	}
+	re.Sub = subs
+	if re.Op == syntax.OpConcat {
+		re.Sub = joinLiterals(subs, positions)
+		if len(re.Sub) == 1 {
+			re = re.Sub[0]
+		}
+	}
+
+	if _, ok := positions[re]; !ok {
+		if written.start < 0 && len(re.Sub) > 0 {
+			written = span{positions[re.Sub[0]].start, positions[re.Sub[len(re.Sub)-1]].end}
+		}
+		positions[re] = written
+	}
+	return re
+}
+
+// Joins runs of literals with the same flags into single literals, each written up
+// to where the last literal of its run is.
+func joinLiterals(subs []*syntax.Regexp, positions map[*syntax.Regexp]span) []*syntax.Regexp {
+	joined := []*syntax.Regexp{}
+	for _, sub := range subs {
+		if n := len(joined); n > 0 && sub.Op == syntax.OpLiteral && joined[n-1].Op == syntax.OpLiteral && sub.Flags == joined[n-1].Flags {
+			joined[n-1].Rune = append(joined[n-1].Rune, sub.Rune...)
+			written := positions[joined[n-1]]
+			written.end = positions[sub].end
+			positions[joined[n-1]] = written
+			continue
+		}
+		joined = append(joined, sub)
+	}
+	return joined
+}
+
+// The part of the pattern a node of the regex is written as, or if it isn't written
+// anywhere, the node as regexp/syntax writes it.
+func writtenAs(re *syntax.Regexp, pattern string, positions map[*syntax.Regexp]span) string {
+	if written, ok := positions[re]; ok && written.start >= 0 {
+		return pattern[written.start:written.end]
+	}
+	return re.String()
+}
@@ -0,0 +1,78 @@
+package regrev
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// A Strategy decides how a reverser makes the choices a regex leaves open: how many
+// times to repeat, which alternative to take, and which character to pick.
+type Strategy int
+
+const (
+	// Random makes every choice at random. It is the default.
+	Random Strategy = iota
+
+	// Shortest produces the shortest string the regex accepts. Every repetition
+	// takes its minimum, "?" and "*" as zero and "+" as one, every alternation takes
+	// its shortest alternative, and every character is the lowest one available.
+	// If that string can't match, each retry steps some of the choices to the next
+	// shortest alternative, repeat count or character instead.
+	Shortest
+
+	// Longest produces the longest string the regex accepts. Every repetition takes
+	// its maximum, with unbounded ones capped at maxRepeats, every alternation takes
+	// its longest alternative, and every character is the highest one available.
+	// Retries step choices to the next longest option, the same way as Shortest.
+	Longest
+)
+
+// UseStrategy sets the strategy the reverser makes its choices with. A BranchSelector
+// still has the final say over alternations.
+func UseStrategy(st Strategy) func(*RegexReverser) error {
+	return func(rr *RegexReverser) error {
+		if st < Random || st > Longest {
+			return errors.Errorf("unknown Strategy %d provided to UseStrategy", st)
+		}
+		rr.strategy = st
+		return nil
+	}
+}
+
+// Which of a number of options, ranked from first to last, a fixed strategy takes.
+// The first attempt always takes the first option. So that a retry doesn't repeat the
+// choices that just failed, each retry steps about a quarter of its choices on to the
+// next option, and every 16 retries, choices can step one option further.
+func (s *solution) rank(options int) int {
+	k := 0
+	for k < options-1 && k < (s.attempt+15)/16 && s.rnd.Intn(4) == 0 {
+		k++
+	}
+	return k
+}
+
+// Picks an alternative by the length of its strings, according to the strategy. The
+// alternatives are ranked by their shortest strings for Shortest, and by their
+// longest strings for Longest, earlier ones first where they tie. Only the candidate
+// alternatives are considered, and those that can't be solved at all are passed over.
+func (s *solution) byLength(a *alternation, candidates []int) int {
+	ranked := []int{}
+	bounds := map[int]int{}
+	for _, i := range candidates {
+		min, max := extent(s.lengthsOf(a.alternatives[i]))
+		if min < 0 {
+			continue
+		}
+		ranked = append(ranked, i)
+		bounds[i] = min
+		if s.rr.strategy == Longest {
+			bounds[i] = -max
+		}
+	}
+	if len(ranked) == 0 {
+		return candidates[0]
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return bounds[ranked[i]] < bounds[ranked[j]] })
+	return ranked[s.rank(len(ranked))]
+}
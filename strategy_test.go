@@ -0,0 +1,90 @@
+package regrev_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestStrategies(t *testing.T) {
+	shortest, err := regrev.NewRegexReverser(regrev.UseStrategy(regrev.Shortest), regrev.MaxRepeats(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	longest, err := regrev.NewRegexReverser(regrev.UseStrategy(regrev.Longest), regrev.MaxRepeats(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Reg      *regexp.Regexp
+		Shortest string
+		Longest  string
+	}{
+		{regexp.MustCompile(`a?b*c+d{2,4}(xy|z)`), "cddz", "abbbcccddddxy"},
+		{regexp.MustCompile(`[a-c]{2}\d`), "aa0", "cc9"},
+		{regexp.MustCompile(`(?i)ok`), "OK", "ok"},
+		{regexp.MustCompile(`(cat|horse|ox)s?`), "ox", "horses"},
+		{regexp.MustCompile(`(ab(c|de)?){1,2}`), "ab", "abdeabde"},
+		{regexp.MustCompile(`x{5,}`), "xxxxx", "xxxxx"},
+		{regexp.MustCompile(`^(?:)$`), "", ""},
+	}
+
+	for _, tc := range cases {
+		got, err := shortest.Reverse(tc.Reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.Shortest {
+			t.Errorf("expected the shortest string for %s to be `%s`, got `%s`", tc.Reg, tc.Shortest, got)
+		}
+
+		got, err = longest.Reverse(tc.Reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.Longest {
+			t.Errorf("expected the longest string for %s to be `%s`, got `%s`", tc.Reg, tc.Longest, got)
+		}
+	}
+}
+
+func TestStrategyRetries(t *testing.T) {
+	shortest, err := regrev.NewRegexReverser(regrev.UseStrategy(regrev.Shortest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	longest, err := regrev.NewRegexReverser(regrev.UseStrategy(regrev.Longest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		RR       *regrev.RegexReverser
+		Reg      *regexp.Regexp
+		Expected string
+	}{
+		{shortest, regexp.MustCompile(`^x(|!)\by$`), "x!y"},
+		{shortest, regexp.MustCompile(`^([^\x00-\x{10FFFF}]|ab)$`), "ab"},
+		{shortest, regexp.MustCompile(`^a*\b!$`), "a!"},
+		{longest, regexp.MustCompile(`^(ab!|a)\b$`), "a"},
+		{longest, regexp.MustCompile(`^[!a]\bx$`), "!x"},
+	}
+
+	for _, tc := range cases {
+		for i := 0; i < 20; i++ {
+			got, err := tc.RR.Reverse(tc.Reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.Expected {
+				t.Errorf("expected `%s` for %s, got `%s`", tc.Expected, tc.Reg, got)
+			}
+		}
+	}
+
+	if _, err := regrev.NewRegexReverser(regrev.UseStrategy(regrev.Strategy(7))); err == nil {
+		t.Error("expected an error for a Strategy that doesn't exist")
+	}
+}
@@ -0,0 +1,136 @@
+package regrev
+
+import (
+	"math/big"
+	"math/rand"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// A UniformSampler produces strings that match a regex, giving every string that
+// matches it as a whole, the ones Count counts, the same chance. Reverse makes each
+// choice on its own, so `a|[a-z]{10}` produces "a" half the time. A UniformSampler
+// weighs each choice by how many strings follow from it, using tables counted once, up
+// front. Regexes with infinitely many strings are sampled up to the same length Count
+// counts them up to.
+//
+// A UniformSampler is safe for concurrent use by multiple goroutines, and takes its
+// seeds from the reverser that made it.
+type UniformSampler struct {
+	rr  *RegexReverser
+	reg *regexp.Regexp
+
+	// The automaton fills in its tables as they are needed, so it is guarded by mu.
+	mu sync.Mutex
+	a  *automaton
+}
+
+// UniformSampler prepares a UniformSampler for the regex.
+func (rr *RegexReverser) UniformSampler(reg *regexp.Regexp) (*UniformSampler, error) {
+	a, err := rr.automaton(reg)
+	if err != nil {
+		return nil, err
+	}
+	if a.upTo(0, a.bound).Sign() == 0 {
+		return nil, errors.Errorf("regexp %s cannot match any string", reg)
+	}
+	return &UniformSampler{rr: rr, reg: reg, a: a}, nil
+}
+
+// Sample produces a string, giving every string the regex matches the same chance.
+func (us *UniformSampler) Sample() (string, error) {
+	return us.sample(us.a.bound, false)
+}
+
+// SampleLength produces a string of exactly n characters, giving every string of that
+// length the regex matches the same chance. Strings of any one length are finite in
+// number, so n may be longer than the strings Sample produces.
+func (us *UniformSampler) SampleLength(n int) (string, error) {
+	if n < 0 {
+		return "", errors.Errorf("cannot sample strings of negative length %d", n)
+	}
+	return us.sample(n, true)
+}
+
+func (us *UniformSampler) sample(n int, exact bool) (string, error) {
+	rnd := rand.New(rand.NewSource(us.rr.nextSeed()))
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	count := us.a.weight(0, n, exact)
+	if count.Sign() == 0 {
+		return "", errors.Errorf("regexp %s cannot match any string of length %d", us.reg, n)
+	}
+	return string(us.a.unrank(0, n, exact, new(big.Int).Rand(rnd, count))), nil
+}
+
+// The number of distinct strings of exactly n more characters that take the state to
+// an accepting one.
+func (a *automaton) count(s, n int) *big.Int {
+	if a.countMemo == nil {
+		a.countMemo = map[[2]int]*big.Int{}
+	}
+	key := [2]int{s, n}
+	if count, ok := a.countMemo[key]; ok {
+		return count
+	}
+
+	count := big.NewInt(0)
+	if n == 0 {
+		if a.states[s].accept {
+			count.SetInt64(1)
+		}
+	} else {
+		for _, e := range a.states[s].edges {
+			paths := new(big.Int).Mul(a.atoms[e.atom].size(), a.count(e.to, n-1))
+			count.Add(count, paths)
+		}
+	}
+	a.countMemo[key] = count
+	return count
+}
+
+// The number of strings from the state of exactly n characters, or if not exact, of at
+// most n.
+func (a *automaton) weight(s, n int, exact bool) *big.Int {
+	if exact {
+		return a.count(s, n)
+	}
+	return a.upTo(s, n)
+}
+
+// The string at index i of the strings from the state, of exactly n characters, or if
+// not exact, of at most n. Strings are in lexicographic order, so shorter strings come
+// before any longer string they are a prefix of.
+func (a *automaton) unrank(s, n int, exact bool, i *big.Int) []rune {
+	result := []rune{}
+	i = new(big.Int).Set(i)
+	for ; n > 0; n-- {
+		if !exact && a.states[s].accept {
+			if i.Sign() == 0 {
+				break
+			}
+			i.Sub(i, big.NewInt(1))
+		}
+
+		for _, e := range a.states[s].edges {
+			each := a.weight(e.to, n-1, exact)
+			paths := new(big.Int).Mul(a.atoms[e.atom].size(), each)
+			if i.Cmp(paths) >= 0 {
+				i.Sub(i, paths)
+				continue
+			}
+
+			offset := new(big.Int)
+			i.DivMod(i, each, offset)
+			result = append(result, a.atoms[e.atom].lo+rune(i.Int64()))
+			i = offset
+			s = e.to
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package regrev_test
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/russellrollins/regrev"
+)
+
+func TestUniformSampler(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(1234))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := regexp.MustCompile(`^(a|[a-z]{10})$`)
+	us, err := rr.UniformSampler(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := 0
+	for i := 0; i < 1000; i++ {
+		got, err := us.Sample()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reg.MatchString(got) {
+			t.Errorf("expected sampled string `%s` to match regexp %s", got, reg)
+		}
+		if got == "a" {
+			short++
+		}
+	}
+	if short > 0 {
+		t.Errorf("expected `a` to be one of ~10^14 strings, but it was sampled %d times out of 1000", short)
+	}
+
+	got, err := us.SampleLength(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a" {
+		t.Errorf("expected the only string of length 1 to be `a`, got `%s`", got)
+	}
+	if _, err := us.SampleLength(5); err == nil {
+		t.Error("expected an error sampling a length the regexp can't match")
+	}
+}
+
+func TestUniformSamplerIsUniform(t *testing.T) {
+	rr, err := regrev.NewRegexReverser(regrev.Seed(1234), regrev.MaxRepeats(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 + 3 + 9 strings, with very different odds under Reverse.
+	reg := regexp.MustCompile(`^(|[abc]|[abc][abc])$`)
+	us, err := rr.UniformSampler(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const samples = 13000
+	seen := map[string]int{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < samples/4; i++ {
+				got, err := us.Sample()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				seen[got]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 13 {
+		t.Errorf("expected to see all 13 strings, saw %v", seen)
+	}
+	for s, n := range seen {
+		if n < 800 || n > 1200 {
+			t.Errorf("expected `%s` about 1000 times out of %d, saw it %d times", s, samples, n)
+		}
+	}
+
+	if _, err := rr.UniformSampler(regexp.MustCompile(`a^b`)); err == nil {
+		t.Error("expected an error for a regexp with no strings to sample")
+	}
+}